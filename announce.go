@@ -0,0 +1,66 @@
+package tcpserve
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// maxAnnounceBody is the largest `AnnounceRequest` body `AnnounceHandler` reads
+const maxAnnounceBody = 64 << 10
+
+// An AnnounceRequest is the notice POSTed to an `AnnounceHandler`
+type AnnounceRequest struct {
+	Message string            `json:"message"`
+	Tags    map[string]string `json:"tags"` // Only sessions carrying every one of these tags receive the notice
+}
+
+// An AnnounceResponse is the result returned by an `AnnounceHandler`
+type AnnounceResponse struct {
+	Sent int `json:"sent"` // Number of sessions the notice was written to
+}
+
+// AnnounceHandler returns an `http.Handler` which announces the notices POSTed to it to the server's sessions
+//
+// The body is a JSON `AnnounceRequest` and the reply a JSON `AnnounceResponse`. `build` turns the notice into each
+// recipient's packet, as the `builder` of `Announce`, and may return nil to skip a session. The handler does not
+// authenticate anyone: serve it on an address only operators can reach.
+func (s *Server) AnnounceHandler(build func(session *Session, message string) []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req AnnounceRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAnnounceBody)).Decode(&req); err != nil {
+			http.Error(w, "invalid announcement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Message == "" {
+			http.Error(w, "invalid announcement: empty message", http.StatusBadRequest)
+			return
+		}
+
+		sent := s.Announce(func(session *Session) []byte {
+			return build(session, req.Message)
+		}, func(session *Session) bool {
+			return hasTags(session, req.Tags)
+		})
+		s.logInfo("Announced notice", Field{"sent", sent}, Field{"remote", r.RemoteAddr})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AnnounceResponse{Sent: sent})
+	})
+}
+
+// hasTags reports whether `session` carries every tag in `tags`
+func hasTags(session *Session, tags map[string]string) bool {
+	for key, value := range tags {
+		if tag, ok := session.Tag(key); !ok || tag != value {
+			return false
+		}
+	}
+
+	return true
+}
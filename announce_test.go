@@ -0,0 +1,79 @@
+package tcpserve
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnounceHandler(t *testing.T) {
+	s, wg := startTestServer(t)
+	defer wg.Wait()
+	defer s.Stop()
+
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		defer conn.Close()
+		clients = append(clients, conn)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for s.Count() < len(clients) {
+		if time.Now().After(deadline) {
+			t.Fatal("clients did not become sessions")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Sessions()[0].SetTag("region", "eu")
+
+	handler := s.AnnounceHandler(func(session *Session, message string) []byte {
+		return []byte(message)
+	})
+	announce := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/announce", strings.NewReader(body)))
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		sent   int
+	}{
+		{name: "everyone", method: http.MethodPost, body: `{"message":"maintenance"}`, status: http.StatusOK, sent: 2},
+		{name: "tagged", method: http.MethodPost, body: `{"message":"eu only","tags":{"region":"eu"}}`, status: http.StatusOK, sent: 1},
+		{name: "unknown tag", method: http.MethodPost, body: `{"message":"nobody","tags":{"region":"us"}}`, status: http.StatusOK},
+		{name: "empty message", method: http.MethodPost, body: `{}`, status: http.StatusBadRequest},
+		{name: "malformed", method: http.MethodPost, body: `{`, status: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := announce(test.method, test.body)
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, want %d", rec.Code, test.status)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var res AnnounceResponse
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if res.Sent != test.sent {
+				t.Errorf("sent = %d, want %d", res.Sent, test.sent)
+			}
+		})
+	}
+}
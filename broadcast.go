@@ -0,0 +1,166 @@
+package tcpserve
+
+import (
+	"sync"
+)
+
+// defaultBroadcastEntries is the number of broadcast keys cached unless `WithBroadcastCache` says otherwise
+const defaultBroadcastEntries = 64
+
+// WithCryptoClass returns a `SessionOption` which the Session constructor uses to modify its `cryptoClass` member
+//
+// Sessions sharing a non-empty class promise to encrypt and frame any packet to the same bytes, as stateless codecs
+// with a common key do, which lets `BroadcastCached` encode a broadcast once per class instead of once per session.
+// Sessions with per-connection cipher state must keep the empty class.
+func WithCryptoClass(class string) SessionOption {
+	return func(s *Session) {
+		s.cryptoClass = class
+	}
+}
+
+// SetCryptoClass changes the session's crypto class, for instance once a handshake settled its key
+func (s *Session) SetCryptoClass(class string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.cryptoClass = class
+}
+
+// CryptoClass returns the session's crypto class, see `WithCryptoClass`
+func (s *Session) CryptoClass() string {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.cryptoClass
+}
+
+// WithBroadcastCache returns a `ServerOption` which the Server constructor uses to modify its `broadcasts` member
+//
+// `entries` is the number of broadcast keys `BroadcastCached` remembers, 64 by default; the oldest key is forgotten
+// first. 0 disables caching.
+func WithBroadcastCache(entries int) ServerOption {
+	return func(s *Server) {
+		s.broadcasts = newBroadcastCache(entries)
+	}
+}
+
+// broadcastCache keeps the wire bytes of repeated broadcasts, per key and crypto class
+type broadcastCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]map[string][]byte // Encoded frames, by broadcast key then crypto class
+	order   []string                     // Cached keys, oldest first
+}
+
+// newBroadcastCache creates a cache holding up to `max` keys
+func newBroadcastCache(max int) *broadcastCache {
+	return &broadcastCache{max: max, entries: make(map[string]map[string][]byte)}
+}
+
+// get returns the frame cached for `key` and `class`
+func (c *broadcastCache) get(key, class string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	frame, ok := c.entries[key][class]
+
+	return frame, ok
+}
+
+// put caches `frame` for `key` and `class`, evicting the oldest key if the cache is full
+func (c *broadcastCache) put(key, class string, frame []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.max < 1 {
+		return // Caching is disabled
+	}
+
+	frames, ok := c.entries[key]
+	if !ok {
+		if len(c.order) >= c.max {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		frames = make(map[string][]byte)
+		c.entries[key] = frames
+		c.order = append(c.order, key)
+	}
+	frames[class] = frame
+}
+
+// forget drops every frame cached for `key`
+func (c *broadcastCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		return
+	}
+	delete(c.entries, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// BroadcastCached sends `message` to every session accepted by `filter`, reusing the wire bytes cached under `key`
+//
+// `key` names the message's content: broadcasting different content under a cached key sends the old bytes, so
+// rotate keys or call `ForgetBroadcast` when the content changes. Sessions with a crypto class get the frame cached
+// for their class, encoded by the first of them on a miss; other sessions are encoded individually with `Write`.
+// A nil `filter` accepts every session, and may run concurrently. It returns the number of sessions the message was
+// sent to.
+func (s *Server) BroadcastCached(key string, message []byte, filter func(*Session) bool) int {
+	return s.fanOut(s.sessions.snapshot(), func(session *Session) bool {
+		if filter != nil && !filter(session) {
+			return false
+		}
+
+		if _, err := s.writeCached(session, key, message); err != nil {
+			s.logError("Could not broadcast to client", Field{"session", session.id}, Field{"error", err})
+			return false
+		}
+
+		return true
+	})
+}
+
+// ForgetBroadcast drops the wire bytes cached under `key`
+func (s *Server) ForgetBroadcast(key string) {
+	s.broadcasts.forget(key)
+}
+
+// writeCached sends `message` to `session`, through the broadcast cache when the session's crypto allows sharing
+func (s *Server) writeCached(session *Session, key string, message []byte) (int, error) {
+	data := append([]byte(nil), message...) // Encrypters may work in place, keep `message` intact for the others
+
+	class := session.CryptoClass()
+	if class == "" {
+		return session.Write(data)
+	}
+
+	if frame, ok := s.broadcasts.get(key, class); ok {
+		return session.WriteRaw(frame)
+	}
+
+	frame := session.encode(data)
+	s.broadcasts.put(key, class, frame)
+
+	return session.WriteRaw(frame)
+}
+
+// encode returns `data` encrypted and framed as `Write` would send it, in a slice of its own
+func (s *Session) encode(data []byte) []byte {
+	s.codecMu.RLock()
+	defer s.codecMu.RUnlock()
+
+	s.trace(traceOut, tracePlain, data)
+	if s.appendEncrypt != nil {
+		return s.framer.Frame(s.appendEncrypt(nil, data))
+	}
+
+	return append([]byte(nil), s.framer.Frame(s.encrypt(data))...) // The framer may hand back the encrypter's buffer
+}
@@ -0,0 +1,73 @@
+package tcpserve
+
+import (
+	"sync"
+)
+
+// A BufferPool supplies the buffers the read path reads and decodes packets into
+//
+// Buffers handed out by `Get` must have a length of exactly `size`. Every buffer is given back with `Put` once the
+// server is done with it, after which its contents may be overwritten.
+type BufferPool interface {
+	Get(size int) []byte
+	Put(buf []byte)
+}
+
+// WithBufferPool returns a `ServerOption` which the Server constructor uses to modify its `buffers` member
+//
+// Packets handed to `onPacket`, `onRawPacket`, router handlers and middleware live in buffers from this pool and
+// are only valid until the callback returns: handlers that keep a packet (in a goroutine, a channel or session
+// state) must copy it first. Without this option a shared `sync.Pool` based pool is used.
+func WithBufferPool(pool BufferPool) ServerOption {
+	return func(s *Server) {
+		s.buffers = pool
+	}
+}
+
+// Size classes of the default buffer pool, as powers of two
+const (
+	minBufferClass = 6  // 64 bytes
+	maxBufferClass = 16 // 64 KiB, larger buffers are not pooled
+)
+
+// syncBufferPool is the default `BufferPool`, keeping a `sync.Pool` per power of two size class
+type syncBufferPool struct {
+	classes [maxBufferClass - minBufferClass + 1]sync.Pool
+}
+
+// defaultBuffers is shared by every server and client without a `BufferPool` of their own
+var defaultBuffers = &syncBufferPool{}
+
+// class returns the index of the smallest size class holding `size` bytes, or -1 if it is too large to pool
+func (p *syncBufferPool) class(size int) int {
+	for i := range p.classes {
+		if size <= 1<<(minBufferClass+i) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (p *syncBufferPool) Get(size int) []byte {
+	class := p.class(size)
+	if class < 0 {
+		return make([]byte, size)
+	}
+
+	if buf, ok := p.classes[class].Get().(*[]byte); ok {
+		return (*buf)[:size]
+	}
+
+	return make([]byte, size, 1<<(minBufferClass+class))
+}
+
+func (p *syncBufferPool) Put(buf []byte) {
+	class := p.class(cap(buf))
+	if class < 0 || cap(buf) != 1<<(minBufferClass+class) {
+		return // Not one of ours, let the GC have it
+	}
+
+	buf = buf[:0]
+	p.classes[class].Put(&buf)
+}
@@ -0,0 +1,35 @@
+package tcpserve
+
+import (
+	"sync/atomic"
+)
+
+// A Capability is a protocol feature flag negotiated with the client, typically during the handshake
+type Capability uint64
+
+// SetCapabilities replaces the session's negotiated capabilities
+func (s *Session) SetCapabilities(caps Capability) {
+	atomic.StoreUint64(&s.capabilities, uint64(caps))
+}
+
+// Capabilities returns the session's negotiated capabilities
+func (s *Session) Capabilities() Capability {
+	return Capability(atomic.LoadUint64(&s.capabilities))
+}
+
+// HasCapability reports whether every capability in `flag` has been negotiated for the session
+func (s *Session) HasCapability(flag Capability) bool {
+	return s.Capabilities()&flag == flag
+}
+
+// WriteCapable encrypts and sends the packet built by `with` if the session has `flag`, or the one built by `without`
+// otherwise, so version-conditional encoding lives in one place instead of in every handler
+//
+// Only the chosen builder is called.
+func (s *Session) WriteCapable(flag Capability, with func() []byte, without func() []byte) (int, error) {
+	if s.HasCapability(flag) {
+		return s.Write(with())
+	}
+
+	return s.Write(without())
+}
@@ -0,0 +1,90 @@
+package tcpserve
+
+import "sync"
+
+// changesBufferSize is the number of deltas `Changes` buffers for a slow consumer before dropping them
+const changesBufferSize = 1024
+
+// A DeltaKind tells what a `RegistryDelta` reports
+type DeltaKind int
+
+const (
+	DeltaConnect    DeltaKind = iota // A session joined the registry
+	DeltaDisconnect                  // A session left the registry
+	DeltaTag                         // A session's tag was set
+)
+
+// A RegistryDelta is one change to the server's set of sessions
+type RegistryDelta struct {
+	Seq     uint64            // Increases by one with every delta, so gaps reveal dropped deltas
+	Kind    DeltaKind         // What changed
+	Session int               // ID of the session that changed
+	Tags    map[string]string // The session's tags, for `DeltaConnect`
+	Key     string            // Tag that was set, for `DeltaTag`
+	Value   string            // New value of `Key`, for `DeltaTag`
+}
+
+// changeStream publishes registry deltas to the channel returned by `Changes`
+type changeStream struct {
+	mu     sync.Mutex
+	seq    uint64
+	ch     chan RegistryDelta // Created by the first `Changes` call
+	closed bool
+}
+
+// Changes returns a stream of connect, disconnect and tag deltas, so presence services and matchmakers can mirror
+// the server's sessions without polling `Sessions`
+//
+// Every call returns the same channel, which is closed once the server has stopped. The server never waits for the
+// consumer: deltas are dropped while the channel's buffer is full, which shows as a gap in `Seq`, after which the
+// consumer should resynchronize from `Sessions`.
+func (s *Server) Changes() <-chan RegistryDelta {
+	c := s.changes
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ch == nil {
+		c.ch = make(chan RegistryDelta, changesBufferSize)
+		if c.closed {
+			close(c.ch)
+		}
+	}
+
+	return c.ch
+}
+
+// publish numbers `delta` and hands it to the consumer, if there is one with room for it
+func (c *changeStream) publish(delta RegistryDelta) {
+	if c == nil {
+		return // Session is not owned by a server
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ch == nil || c.closed {
+		return
+	}
+
+	c.seq += 1
+	delta.Seq = c.seq
+	select {
+	case c.ch <- delta:
+	default: // Consumer is behind, it will notice the gap
+	}
+}
+
+// close ends the stream
+func (c *changeStream) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.ch != nil {
+		close(c.ch)
+	}
+}
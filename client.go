@@ -0,0 +1,232 @@
+package tcpserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected is returned by `Client.Write` while the client has no connection
+var ErrNotConnected = errors.New("tcpserve: client is not connected")
+
+// A Client dials a server and runs its packets through the same framing and codec pipeline as the Server
+type Client struct {
+	addr           string                 // Address of the server to dial
+	dialer         *net.Dialer            // Dialer used for every connection attempt
+	sessionOptions []SessionOption        // Options applied to every session, such as its codecs and framer
+	reconnect      bool                   // Whether to redial after the connection is lost
+	minBackoff     time.Duration          // Delay before the first reconnection attempt
+	maxBackoff     time.Duration          // Longest delay between reconnection attempts
+	onPacket       func(*Session, []byte) // Callback function when a new packet is received
+	onConnected    func(*Session)         // Callback function when a connection is made
+	onDisconnected func(*Session, error)  // Callback function when a connection is lost
+	errLog         Logger
+	log            Logger
+	session        *Session   // Current session, nil while disconnected
+	mu             sync.Mutex // Guards `session`
+}
+
+type ClientOption func(*Client)
+
+// NewClient creates a Client for the server at `addr`
+func NewClient(addr string, options ...ClientOption) *Client {
+	discard := func(string) {} // Loggers stay silent unless `WithClientLoggers` is used
+
+	// Create Client object
+	c := &Client{
+		addr:       addr,
+		dialer:     &net.Dialer{Timeout: 10 * time.Second},
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		log:        discard,
+		errLog:     discard,
+	}
+
+	// Call each option
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// minReconnectBackoff is the shortest wait `WithReconnect` allows between attempts, so a client never spins
+const minReconnectBackoff = 10 * time.Millisecond
+
+// WithReconnect returns a `ClientOption` which the Client constructor uses to modify its `reconnect` members
+//
+// After losing its connection the client redials, waiting `min` before the first attempt and doubling the wait
+// after every failed attempt, up to `max`. `min` is raised to 10ms if shorter, and `max` to `min`.
+func WithReconnect(min, max time.Duration) ClientOption {
+	if min < minReconnectBackoff {
+		min = minReconnectBackoff
+	}
+	if max < min {
+		max = min
+	}
+
+	return func(c *Client) {
+		c.reconnect = true
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithDialer returns a `ClientOption` which the Client constructor uses to modify its `dialer` member
+func WithDialer(dialer *net.Dialer) ClientOption {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// WithClientSessionOptions returns a `ClientOption` which the Client constructor uses to modify its `sessionOptions` member
+func WithClientSessionOptions(options ...SessionOption) ClientOption {
+	return func(c *Client) {
+		c.sessionOptions = append(c.sessionOptions, options...)
+	}
+}
+
+// WithClientLoggers returns a `ClientOption` which the Client constructor uses to modify its `logger` members
+//
+// If the `errLogger` parameter is left empty, then the errLogger function would use the `logger` parameter with [Error] prefixed.
+func WithClientLoggers(logger Logger, errLogger Logger) ClientOption {
+	return func(c *Client) {
+		c.log = logger
+		c.errLog = errLogger
+
+		if errLogger == nil {
+			c.errLog = func(msg string) {
+				c.log(fmt.Sprint("[Error]", msg))
+			}
+		}
+	}
+}
+
+// OnPacket sets the callback invoked for every decoded packet
+//
+// The packet is only valid until `onPacket` returns; copy it to keep it longer.
+func (c *Client) OnPacket(onPacket func(*Session, []byte)) {
+	c.onPacket = onPacket
+}
+
+// OnConnected sets the callback invoked every time a connection is made, before any packet is read
+func (c *Client) OnConnected(onConnected func(*Session)) {
+	c.onConnected = onConnected
+}
+
+// OnDisconnected sets the callback invoked every time the connection is lost, with the error that ended it
+func (c *Client) OnDisconnected(onDisconnected func(*Session, error)) {
+	c.onDisconnected = onDisconnected
+}
+
+// Session returns the client's current session, or nil while it is disconnected
+func (c *Client) Session() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.session
+}
+
+// Write encrypts and sends a slice of bytes on the current session
+func (c *Client) Write(data []byte) (int, error) {
+	session := c.Session()
+	if session == nil {
+		return 0, ErrNotConnected
+	}
+
+	return session.Write(data)
+}
+
+// Run connects to the server and handles its packets until `ctx` is done
+//
+// Without `WithReconnect`, Run returns as soon as the connection is lost or cannot be made. With it, Run keeps
+// redialing with exponential backoff and only returns once `ctx` is done.
+func (c *Client) Run(ctx context.Context) error {
+	backoff := c.minBackoff
+
+	for {
+		conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+		if err == nil {
+			backoff = c.minBackoff // Connected, the next outage starts over
+			err = c.serve(ctx, conn)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !c.reconnect {
+			return err
+		}
+
+		c.errLog(fmt.Sprintf("Connection to %s lost, reconnecting in %s: %s", c.addr, backoff, err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// serve handles a single connection until it is lost or `ctx` is done
+func (c *Client) serve(ctx context.Context, conn net.Conn) error {
+	ctx, cancel := context.WithCancel(ctx) // Session context ends with the connection
+	defer cancel()
+
+	options := append([]SessionOption{}, c.sessionOptions...)
+	options = append(options, WithConn(conn), WithContext(ctx), WithLogger(c.log))
+	session := NewSession(options...)
+
+	c.mu.Lock()
+	c.session = session
+	c.mu.Unlock()
+
+	// Close the connection when `ctx` is done to unblock the read loop
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	c.log(fmt.Sprintf("Connected to %s", c.addr))
+	if c.onConnected != nil {
+		c.onConnected(session)
+	}
+
+	reason := readFrames(session, defaultBuffers, time.Now, func(wire, packet []byte) error {
+		data := defaultBuffers.Get(len(packet)) // Decode into a buffer of our own, the framer's is reused
+		defer defaultBuffers.Put(data)          // Packets are only valid during the callback, as on the Server
+		copy(data, packet)
+		res := session.Decrypt(data) // Decrypt data if there is a decrypter
+
+		if session.resolve(res) || session.collect(res) {
+			return nil // Packet was claimed by a pending Request or ReadExpect
+		}
+		if c.onPacket != nil {
+			c.onPacket(session, res)
+		}
+
+		return nil
+	})
+	if session.closedLocally() {
+		reason = ErrSessionClosed
+	}
+
+	c.mu.Lock()
+	c.session = nil
+	c.mu.Unlock()
+
+	if c.onDisconnected != nil {
+		c.onDisconnected(session, reason)
+	}
+
+	return reason
+}
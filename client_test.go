@@ -0,0 +1,29 @@
+package tcpserve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithReconnectClampsBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max time.Duration
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{name: "valid", min: time.Second, max: time.Minute, wantMin: time.Second, wantMax: time.Minute},
+		{name: "zero min", min: 0, max: time.Second, wantMin: minReconnectBackoff, wantMax: time.Second},
+		{name: "max below min", min: time.Second, max: time.Millisecond, wantMin: time.Second, wantMax: time.Second},
+		{name: "both zero", wantMin: minReconnectBackoff, wantMax: minReconnectBackoff},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewClient("127.0.0.1:0", WithReconnect(test.min, test.max))
+			if c.minBackoff != test.wantMin || c.maxBackoff != test.wantMax {
+				t.Errorf("backoff = %s to %s, want %s to %s", c.minBackoff, c.maxBackoff, test.wantMin, test.wantMax)
+			}
+		})
+	}
+}
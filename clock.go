@@ -0,0 +1,77 @@
+package tcpserve
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithCoarseClock returns a `ServerOption` which the Server constructor uses to modify its `clockResolution` member
+//
+// Instead of calling `time.Now` for every packet, the server timestamps packets with a cached time refreshed every
+// `resolution`, trading timestamp precision for fewer clock reads at very high packet rates.
+func WithCoarseClock(resolution time.Duration) ServerOption {
+	return func(s *Server) {
+		s.clockResolution = resolution
+	}
+}
+
+// coarseClock caches the current time, refreshed by a ticker
+type coarseClock struct {
+	now  int64         // Cached time in Unix nanoseconds
+	stop chan struct{} // Closed to stop refreshing
+}
+
+// startCoarseClock creates a clock refreshed every `resolution` until it is stopped
+func startCoarseClock(resolution time.Duration) *coarseClock {
+	c := &coarseClock{
+		now:  time.Now().UnixNano(),
+		stop: make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(resolution)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case t := <-ticker.C:
+				atomic.StoreInt64(&c.now, t.UnixNano())
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+// Now returns the cached time
+func (c *coarseClock) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.now))
+}
+
+// Stop stops refreshing the clock
+func (c *coarseClock) Stop() {
+	close(c.stop)
+}
+
+// now returns the current time according to the server's clock
+func (s *Server) now() time.Time {
+	if clock, ok := s.clock.Load().(*coarseClock); ok {
+		return clock.Now()
+	}
+
+	return time.Now()
+}
+
+// LastActive returns when the session last received a packet, or when it connected if it has not received any
+//
+// The precision depends on the server's clock; see `WithCoarseClock`.
+func (s *Session) LastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastActive))
+}
+
+// touch records activity on the session at `t`
+func (s *Session) touch(t time.Time) {
+	atomic.StoreInt64(&s.lastActive, t.UnixNano())
+}
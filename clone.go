@@ -0,0 +1,43 @@
+package tcpserve
+
+// WithServerTags returns a `ServerOption` which the Server constructor uses to modify its `tags` member
+//
+// Every session starts with `tags`, such as the channel or region the server stands for; tags from the approval
+// webhook take precedence. Repeating the option replaces the previous tags, which lets `CloneConfig` override them.
+func WithServerTags(tags map[string]string) ServerOption {
+	return func(s *Server) {
+		s.tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			s.tags[k] = v
+		}
+	}
+}
+
+// CloneConfig returns the options the server was created with, followed by `overrides`
+//
+// Passing the result to `NewServer` spins up another server configured like this one, such as a new channel, with
+// `WithPort` or `WithServerTags` among the overrides to tell them apart; later options win. Objects handed to the
+// options, such as routers, metrics and listeners from `WithListener`, are shared with the clone rather than copied.
+func (s *Server) CloneConfig(overrides ...ServerOption) []ServerOption {
+	options := make([]ServerOption, 0, len(s.options)+len(overrides))
+	options = append(options, s.options...)
+
+	return append(options, overrides...)
+}
+
+// sessionTags returns the tags a new session starts with: the server's, then those of its admission
+func (s *Server) sessionTags(admitted map[string]string) map[string]string {
+	if len(s.tags) == 0 {
+		return admitted
+	}
+
+	tags := make(map[string]string, len(s.tags)+len(admitted))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	for k, v := range admitted {
+		tags[k] = v
+	}
+
+	return tags
+}
@@ -0,0 +1,69 @@
+package tcpserve
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCloseTimeout is how long `Close` waits for the close handshake when `WithCloser` is given no timeout
+const defaultCloseTimeout = 5 * time.Second
+
+// ErrSessionClosed is reported to `onDisconnected` for sessions closed locally with `Session.Close`
+var ErrSessionClosed = errors.New("tcpserve: session closed")
+
+// A Closer performs a protocol's close handshake on a session, such as sending a close frame and awaiting its ack
+//
+// The session's read loop keeps running while the Closer does, so acks can be awaited with `ReadExpect` or `Request`.
+type Closer func(s *Session) error
+
+// WithCloser returns a `SessionOption` which the Session constructor uses to modify its `closer` and `closeTimeout` members
+//
+// `Close` runs `closer` before closing the connection, and closes it anyway once `timeout` has passed. A `timeout` of 0
+// gives the handshake 5 seconds.
+func WithCloser(closer Closer, timeout time.Duration) SessionOption {
+	return func(s *Session) {
+		s.closer = closer
+		s.closeTimeout = timeout
+	}
+}
+
+// Close ends the session, running its close handshake first if it has a `Closer`
+//
+// The connection is closed once the handshake completes, fails or times out. Because the handshake's ack is read by
+// the session's own read loop, Close should not be called from the session's packet callback when the Closer awaits
+// an ack: it would always run into the timeout. Errors from the handshake are returned if closing succeeded.
+func (s *Session) Close() (err error) {
+	atomic.StoreInt32(&s.closing, 1) // Mark the coming read error as our own doing
+	s.Record(EventClose, "")
+
+	if s.closer != nil {
+		done := make(chan error, 1)
+		go func() {
+			done <- s.closer(s)
+		}()
+
+		timeout := s.closeTimeout
+		if timeout <= 0 {
+			timeout = defaultCloseTimeout // Never give up before the handshake had a chance
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case err = <-done:
+		case <-timer.C:
+			err = errors.New("tcpserve: close handshake timed out")
+		}
+		timer.Stop()
+	}
+
+	if closeErr := s.Conn().Close(); closeErr != nil {
+		return closeErr
+	}
+
+	return
+}
+
+// closedLocally reports whether `Close` has been called on the session
+func (s *Session) closedLocally() bool {
+	return atomic.LoadInt32(&s.closing) == 1
+}
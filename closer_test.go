@@ -0,0 +1,32 @@
+package tcpserve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloseZeroTimeoutRunsHandshake(t *testing.T) {
+	handshake := func(s *Session) error {
+		time.Sleep(10 * time.Millisecond) // Await the peer's ack
+		return nil
+	}
+	session := NewSession(WithConn(discardConn{}), WithCloser(handshake, 0))
+
+	if err := session.Close(); err != nil {
+		t.Errorf("Close() error = %v, want the handshake to complete", err)
+	}
+}
+
+func TestCloseTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	handshake := func(s *Session) error {
+		<-release // The ack never comes
+		return nil
+	}
+	session := NewSession(WithConn(discardConn{}), WithCloser(handshake, 10*time.Millisecond))
+
+	if err := session.Close(); err == nil {
+		t.Error("Close() succeeded, want the handshake to time out")
+	}
+}
@@ -0,0 +1,137 @@
+package tcpserve
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cryptoCheckNonceSize is the number of random bytes each check frame carries
+const cryptoCheckNonceSize = 8
+
+// ErrCryptoDesync is reported to `onDisconnected` for sessions whose encryption self-check failed
+var ErrCryptoDesync = errors.New("tcpserve: encryption self-check failed")
+
+// A CryptoCheck describes the encryption self-check of `WithCryptoCheck`
+type CryptoCheck struct {
+	Interval time.Duration                               // Time between check frames
+	Timeout  time.Duration                               // Time the client has to echo a check frame
+	Build    func(nonce []byte) []byte                   // Check packet carrying `nonce`, sent through the encrypter
+	Echo     func(packet []byte) (nonce []byte, ok bool) // Nonce of a decrypted echo; ok is false for every other packet
+}
+
+// WithCryptoCheck returns a `ServerOption` which the Server constructor uses to modify its `cryptoCheck` member
+//
+// Every `check.Interval`, each session is sent the packet `check.Build` returns for a fresh random nonce, through
+// its encrypter. The client must echo the nonce back within `check.Timeout`; echoes are recognized by `check.Echo`
+// after decryption and never reach the packet handlers. If the echo is late, or decrypts to another nonce, the keys
+// of both ends have drifted apart and the session is disconnected with `ErrCryptoDesync`, before the desync can
+// corrupt real packets.
+func WithCryptoCheck(check CryptoCheck) ServerOption {
+	return func(s *Server) {
+		s.cryptoCheck = &check
+	}
+}
+
+// cryptoProbe is the check frame a session is waiting to see echoed
+type cryptoProbe struct {
+	mu       sync.Mutex
+	nonce    []byte        // Nonce of the outstanding check frame, nil when none is
+	answered chan struct{} // Closed when the outstanding check frame was echoed correctly
+	failed   int32         // Set once the self-check failed, accessed atomically
+}
+
+// startCryptoCheck sends check frames to `session` until its context ends, closing its connection on a desync
+func (s *Server) startCryptoCheck(session *Session) {
+	check := s.cryptoCheck
+	probe := session.probe
+
+	go func() {
+		ticker := time.NewTicker(check.Interval)
+		defer ticker.Stop()
+
+		ctx := session.Context()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			nonce := make([]byte, cryptoCheckNonceSize)
+			if _, err := rand.Read(nonce); err != nil {
+				s.logError("Could not create encryption check nonce", Field{"session", session.id}, Field{"error", err})
+				continue
+			}
+			answered := probe.expect(nonce)
+			if _, err := session.Write(check.Build(nonce)); err != nil {
+				return // Connection is going away on its own
+			}
+
+			timer := time.NewTimer(check.Timeout)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-answered:
+				timer.Stop()
+			case <-timer.C:
+				s.logError("Encryption check timed out", Field{"session", session.id})
+				atomic.StoreInt32(&probe.failed, 1)
+				session.Conn().Close() // Unblock the read loop
+				return
+			}
+		}
+	}()
+}
+
+// expect records `nonce` as the outstanding check frame, returning a channel closed once it is echoed
+func (p *cryptoProbe) expect(nonce []byte) <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nonce = nonce
+	p.answered = make(chan struct{})
+
+	return p.answered
+}
+
+// checkEcho consumes the client's echo of a check frame, reporting whether `packet` was one
+//
+// An echo of the wrong nonce fails the self-check with `ErrCryptoDesync`; echoes when no check frame is outstanding
+// are dropped, as they can only be late duplicates.
+func (s *Server) checkEcho(session *Session, packet []byte) (bool, error) {
+	if s.cryptoCheck == nil {
+		return false, nil
+	}
+
+	nonce, ok := s.cryptoCheck.Echo(packet)
+	if !ok {
+		return false, nil
+	}
+
+	probe := session.probe
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+
+	switch {
+	case probe.nonce == nil:
+		return true, nil
+	case !bytes.Equal(nonce, probe.nonce):
+		atomic.StoreInt32(&probe.failed, 1)
+		return true, ErrCryptoDesync
+	}
+
+	probe.nonce = nil
+	close(probe.answered)
+
+	return true, nil
+}
+
+// cryptoDesynced reports whether the session was closed for failing the encryption self-check
+func (s *Session) cryptoDesynced() bool {
+	return s.probe != nil && atomic.LoadInt32(&s.probe.failed) == 1
+}
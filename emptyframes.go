@@ -0,0 +1,50 @@
+package tcpserve
+
+// An EmptyFramePolicy decides what happens to frames whose packet is zero bytes long
+type EmptyFramePolicy int
+
+const (
+	DeliverEmptyFrames   EmptyFramePolicy = iota // Hand empty packets to the decrypter and packet handlers like any other
+	DropEmptyFrames                              // Discard empty packets; they still count as activity
+	KeepaliveEmptyFrames                         // Discard empty packets and report them to `WithOnKeepalive` instead
+)
+
+// An EmptyFramer is a `Framer` that chooses what happens to the empty packets it splits
+//
+// Framers that do not implement it deliver empty packets.
+type EmptyFramer interface {
+	Framer
+	EmptyFrames() EmptyFramePolicy
+}
+
+// WithEmptyFrames returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its `empty` member
+//
+// Several protocols send frames with a zero length as heartbeats; `KeepaliveEmptyFrames` keeps them away from
+// decrypters and handlers, which usually expect at least an opcode.
+func WithEmptyFrames(policy EmptyFramePolicy) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.empty = policy
+	}
+}
+
+func (f *LengthPrefixFramer) EmptyFrames() EmptyFramePolicy {
+	return f.empty
+}
+
+// WithOnKeepalive returns a `ServerOption` which the Server constructor uses to modify its `onKeepalive` member
+//
+// `onKeepalive` is called for every empty frame of a framer using `KeepaliveEmptyFrames`.
+func WithOnKeepalive(onKeepalive func(*Session)) ServerOption {
+	return func(s *Server) {
+		s.onKeepalive = onKeepalive
+	}
+}
+
+// emptyFramePolicy returns the policy `framer` applies to empty packets
+func emptyFramePolicy(framer Framer) EmptyFramePolicy {
+	if f, ok := framer.(EmptyFramer); ok {
+		return f.EmptyFrames()
+	}
+
+	return DeliverEmptyFrames
+}
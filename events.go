@@ -0,0 +1,103 @@
+package tcpserve
+
+import "sync"
+
+// eventsBufferSize is the number of events `Events` buffers ahead of the consumer
+const eventsBufferSize = 256
+
+// An EventKind tells what an `Event` reports
+type EventKind int
+
+const (
+	ConnectedEvent    EventKind = iota // A session was accepted, after `onConnected`
+	PacketEvent                        // A session's packet was decoded
+	DisconnectedEvent                  // A session ended, after `onDisconnected`
+	ErrorEvent                         // Accepting a connection or reading from a session failed
+)
+
+// An Event is one thing that happened on the server, delivered by `Events`
+type Event struct {
+	Kind    EventKind
+	Session *Session // Session the event is about; nil for accept errors
+	Packet  []byte   // Copy of the decoded packet, for `PacketEvent`
+	Err     error    // Why the session ended for `DisconnectedEvent`, what failed for `ErrorEvent`
+}
+
+// eventStream delivers events to the channel returned by `Events`
+type eventStream struct {
+	mu     sync.RWMutex // Held for reading while sending, so `close` waits for pending sends
+	ch     chan Event   // Created by the first `Events` call
+	closed bool
+}
+
+// Events returns a stream of connect, packet, disconnect and error events, so the server can be consumed from a
+// select loop instead of concurrency-safe callbacks
+//
+// Call it before `Start`: a server whose only consumer is the stream needs no other packet handler. Every call
+// returns the same channel, which is closed once the server has stopped. Events are never dropped while the server
+// runs, so a consumer that falls behind by more than the channel's buffer holds the sessions up; once the server is
+// stopping, events the consumer has no room for are dropped. Callbacks, the router and middleware still run
+// alongside the stream.
+func (s *Server) Events() <-chan Event {
+	e := s.events
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.ch == nil {
+		e.ch = make(chan Event, eventsBufferSize)
+		if e.closed {
+			close(e.ch)
+		}
+	}
+
+	return e.ch
+}
+
+// enabled reports whether anyone asked for the stream
+func (e *eventStream) enabled() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.ch != nil && !e.closed
+}
+
+// emit hands `event` to the consumer, waiting for room until `done` is closed
+func (e *eventStream) emit(event Event, done <-chan struct{}) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.ch == nil || e.closed {
+		return
+	}
+
+	select {
+	case e.ch <- event:
+		return
+	default:
+	}
+
+	select {
+	case e.ch <- event:
+	case <-done: // Server is stopping, the consumer may be gone
+	}
+}
+
+// close ends the stream
+func (e *eventStream) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.closed = true
+	if e.ch != nil {
+		close(e.ch)
+	}
+}
+
+// emitEvent hands `event` to the stream of `Events`, if it is in use
+func (s *Server) emitEvent(event Event) {
+	s.events.emit(event, s.done)
+}
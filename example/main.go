@@ -1,13 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"log"
+	"net/http"
 	"sync"
 
 	"github.com/matthieutran/tcpserve"
 )
 
 func main() {
+	admin := flag.String("admin", "", "address of the admin endpoint, e.g. localhost:8485")
+	notice := flag.String("announce", "", "announce a notice through the running server's admin endpoint, then exit")
+	flag.Parse()
+
+	if *notice != "" {
+		announce(*admin, *notice)
+		return
+	}
+
 	var wg sync.WaitGroup
 
 	logger := func(msg string) {
@@ -42,7 +55,36 @@ func main() {
 
 	wg.Add(1)
 	server := tcpserve.NewServer(port, loggers, onConnected, onPacket)
+	if *admin != "" {
+		// Notices are sent as a packet of opcode 0x44 followed by the text
+		http.Handle("/announce", server.AnnounceHandler(func(s *tcpserve.Session, message string) []byte {
+			return append([]byte{0x44, 0x00}, message...)
+		}))
+		go func() {
+			log.Println(http.ListenAndServe(*admin, nil))
+		}()
+	}
 	server.Start(&wg)
 
 	wg.Wait()
 }
+
+// announce asks the server behind the admin endpoint at `admin` to announce `notice` to every client
+func announce(admin, notice string) {
+	if admin == "" {
+		log.Fatal("-announce needs the -admin address of the server")
+	}
+
+	body, _ := json.Marshal(tcpserve.AnnounceRequest{Message: notice})
+	resp, err := http.Post("http://"+admin+"/announce", "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var res tcpserve.AnnounceResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&res) != nil {
+		log.Fatalf("announcing failed: %s", resp.Status)
+	}
+	log.Printf("Announced to %d clients", res.Sent)
+}
@@ -0,0 +1,100 @@
+package tcpserve
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrExpectTimeout is passed to a `ReadExpect` callback when the expected packets did not all arrive in time
+var ErrExpectTimeout = errors.New("tcpserve: timed out waiting for expected packets")
+
+// ErrExpectCount is returned by `ReadExpect` when asked for fewer than one packet
+var ErrExpectCount = errors.New("tcpserve: expected packet count must be positive")
+
+// ErrExpectPending is returned by `ReadExpect` when the session is already collecting packets
+var ErrExpectPending = errors.New("tcpserve: session is already collecting packets")
+
+// collector gathers the packets of a pending `ReadExpect`
+type collector struct {
+	mu      sync.Mutex
+	n       int                   // Number of packets expected
+	packets [][]byte              // Packets collected so far
+	done    func([][]byte, error) // Callback invoked once with the outcome
+	timer   *time.Timer           // Fires `done` with ErrExpectTimeout; set under `mu` before it can fire
+	fired   bool                  // Whether `done` has been invoked
+}
+
+// ReadExpect diverts the session's next `n` packets away from the packet handler and delivers them together to `done`
+//
+// If the packets do not all arrive within `timeout`, `done` is called with the packets collected so far and
+// `ErrExpectTimeout`. On success `done` runs on the session's read goroutine; on timeout it runs on its own.
+func (s *Session) ReadExpect(n int, timeout time.Duration, done func(packets [][]byte, err error)) error {
+	if n <= 0 {
+		return ErrExpectCount
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.collector != nil {
+		return ErrExpectPending
+	}
+
+	c := &collector{n: n, done: done}
+	c.mu.Lock() // Keep a timer firing at once from seeing `c.timer` unset
+	defer c.mu.Unlock()
+	c.timer = time.AfterFunc(timeout, func() {
+		s.finishCollecting(c, ErrExpectTimeout)
+	})
+	s.collector = c
+
+	return nil
+}
+
+// collect adds `packet` to the pending `ReadExpect`, returning whether it was consumed
+func (s *Session) collect(packet []byte) bool {
+	s.stateMu.Lock()
+	c := s.collector
+	s.stateMu.Unlock()
+
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	if c.fired {
+		c.mu.Unlock()
+		return false // Timed out in the meantime, the packet goes to the handler as usual
+	}
+	c.packets = append(c.packets, append([]byte(nil), packet...)) // Keep a copy past the read loop iteration
+	complete := len(c.packets) >= c.n
+	c.mu.Unlock()
+
+	if complete {
+		s.finishCollecting(c, nil)
+	}
+
+	return true
+}
+
+// finishCollecting invokes the collector's callback once and clears it from the session
+func (s *Session) finishCollecting(c *collector, err error) {
+	c.mu.Lock()
+	if c.fired {
+		c.mu.Unlock()
+		return
+	}
+	c.fired = true
+	c.timer.Stop()
+	packets := c.packets
+	c.mu.Unlock()
+
+	s.stateMu.Lock()
+	if s.collector == c {
+		s.collector = nil
+	}
+	s.stateMu.Unlock()
+
+	c.done(packets, err)
+}
@@ -0,0 +1,48 @@
+package tcpserve
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadExpectImmediateTimeout(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		session := NewSession()
+		result := make(chan error, 1)
+		if err := session.ReadExpect(1, 0, func(_ [][]byte, err error) { result <- err }); err != nil {
+			t.Fatalf("ReadExpect() error = %v", err)
+		}
+		if err := <-result; !errors.Is(err, ErrExpectTimeout) {
+			t.Fatalf("done error = %v, want %v", err, ErrExpectTimeout)
+		}
+	}
+}
+
+func TestReadExpectCollects(t *testing.T) {
+	session := NewSession()
+	result := make(chan [][]byte, 1)
+	if err := session.ReadExpect(2, time.Minute, func(packets [][]byte, _ error) { result <- packets }); err != nil {
+		t.Fatalf("ReadExpect() error = %v", err)
+	}
+	if err := session.ReadExpect(1, time.Minute, func([][]byte, error) {}); !errors.Is(err, ErrExpectPending) {
+		t.Errorf("second ReadExpect() error = %v, want %v", err, ErrExpectPending)
+	}
+
+	session.collect([]byte("a"))
+	session.collect([]byte("b"))
+	if packets := <-result; len(packets) != 2 || string(packets[1]) != "b" {
+		t.Errorf("collected %q, want [a b]", packets)
+	}
+	if session.collect([]byte("c")) {
+		t.Error("packet collected after ReadExpect completed")
+	}
+}
+
+func TestReadExpectRejectsCount(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		if err := NewSession().ReadExpect(n, time.Minute, func([][]byte, error) {}); !errors.Is(err, ErrExpectCount) {
+			t.Errorf("ReadExpect(%d) error = %v, want %v", n, err, ErrExpectCount)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package tcpserve
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultFanOutChunk is the number of recipients each broadcast worker takes at a time unless configured
+const defaultFanOutChunk = 256
+
+// WithBroadcastParallelism returns a `ServerOption` which the Server constructor uses to modify its `fanOutWorkers`
+// and `fanOutChunk` members
+//
+// `WriteToAll`, `Announce` and `BroadcastCached` split their recipients into chunks of `chunk` sessions, written by
+// up to `workers` goroutines at once; broadcasts to a single chunk stay on the caller's goroutine. By default there
+// is one worker per CPU and chunks of 256 sessions. One worker makes broadcasts serial again.
+func WithBroadcastParallelism(workers, chunk int) ServerOption {
+	return func(s *Server) {
+		s.fanOutWorkers = workers
+		s.fanOutChunk = chunk
+	}
+}
+
+// fanOut calls `send` for every session of `sessions`, spread over the broadcast workers, and returns the number of
+// calls that reported true
+func (s *Server) fanOut(sessions []*Session, send func(*Session) bool) int {
+	chunks := (len(sessions) + s.fanOutChunk - 1) / s.fanOutChunk
+	workers := s.fanOutWorkers
+	if workers > chunks {
+		workers = chunks
+	}
+
+	if workers <= 1 {
+		sent := 0
+		for _, session := range sessions {
+			if send(session) {
+				sent += 1
+			}
+		}
+		return sent
+	}
+
+	var sent int64
+	var next int64 = -1 // Index of the last chunk taken by a worker
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				chunk := int(atomic.AddInt64(&next, 1))
+				if chunk >= chunks {
+					return
+				}
+
+				start := chunk * s.fanOutChunk
+				end := start + s.fanOutChunk
+				if end > len(sessions) {
+					end = len(sessions)
+				}
+				for _, session := range sessions[start:end] {
+					if send(session) {
+						atomic.AddInt64(&sent, 1)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return int(sent)
+}
+
+// defaultFanOutWorkers returns the number of broadcast workers used unless configured
+func defaultFanOutWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,106 @@
+package tcpserve
+
+import (
+	"sync"
+)
+
+// FeatureFlags decides which risky features are enabled for which sessions
+//
+// It is consulted every time a subsystem makes a decision, so changing the provider's answers rolls a feature out
+// or back without restarting the server.
+type FeatureFlags interface {
+	Enabled(feature string, session *Session) bool
+}
+
+// WithFeatureFlags returns a `ServerOption` which the Server constructor uses to modify its `flags` member
+func WithFeatureFlags(flags FeatureFlags) ServerOption {
+	return func(s *Server) {
+		s.flags = flags
+	}
+}
+
+// WithFlaggedFramer returns a `ServerOption` which the Server constructor uses to modify its `flaggedFramers` member
+//
+// New sessions for which `feature` is enabled use `framer` instead of their configured one. Flags are checked once
+// per session when it connects, since a stream cannot change framing midway.
+func WithFlaggedFramer(feature string, framer Framer) ServerOption {
+	return func(s *Server) {
+		s.flaggedFramers = append(s.flaggedFramers, flaggedFramer{feature: feature, framer: framer})
+	}
+}
+
+// flaggedFramer is a framer enabled by a feature flag
+type flaggedFramer struct {
+	feature string
+	framer  Framer
+}
+
+// FeatureEnabled reports whether the server's feature flags enable `feature` for the session
+func (s *Session) FeatureEnabled(feature string) bool {
+	return s.flags != nil && s.flags.Enabled(feature, s)
+}
+
+// applyFlags switches the session to the framers its feature flags enable
+func (s *Server) applyFlags(session *Session) {
+	for _, flagged := range s.flaggedFramers {
+		if session.FeatureEnabled(flagged.feature) {
+			session.SwapCodecs(CodecSet{Framer: flagged.framer}, nil)
+			return
+		}
+	}
+}
+
+// A FlagRule enables a feature for a share of sessions and for specific remote IPs
+type FlagRule struct {
+	Percent int      // Share of sessions, picked by session ID, from 0 to 100
+	IPs     []string // Remote IPs the feature is always enabled for
+}
+
+// A FlagSet is a `FeatureFlags` provider backed by rules which can be replaced at any time
+type FlagSet struct {
+	mu    sync.RWMutex
+	rules map[string]FlagRule
+}
+
+// NewFlagSet creates an empty `FlagSet`, in which every feature is disabled
+func NewFlagSet() *FlagSet {
+	return &FlagSet{rules: make(map[string]FlagRule)}
+}
+
+// Set replaces the rule of `feature`
+func (f *FlagSet) Set(feature string, rule FlagRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules[feature] = rule
+}
+
+// Disable removes the rule of `feature`, disabling it for every session
+func (f *FlagSet) Disable(feature string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.rules, feature)
+}
+
+func (f *FlagSet) Enabled(feature string, session *Session) bool {
+	f.mu.RLock()
+	rule, ok := f.rules[feature]
+	f.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+	if session.id%100 < rule.Percent {
+		return true
+	}
+
+	ip := remoteIP(session.Conn().RemoteAddr())
+	for _, allowed := range rule.IPs {
+		if allowed == ip {
+			return true
+		}
+	}
+
+	return false
+}
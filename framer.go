@@ -0,0 +1,226 @@
+package tcpserve
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// defaultMaxFrameSize is the largest packet a `LengthPrefixFramer` accepts unless `WithMaxFrameSize` says otherwise,
+// as the whole frame is buffered before it is handed on
+const defaultMaxFrameSize = 1 << 20
+
+// ErrFrameTooLarge is returned by a `LengthPrefixFramer` when a frame declares a length over its maximum
+var ErrFrameTooLarge = errors.New("tcpserve: frame exceeds maximum size")
+
+// A Framer splits a connection's byte stream into packets and wraps outgoing packets for the wire
+type Framer interface {
+	// Split returns the first complete frame's packet in `buf` and the number of bytes the frame spans.
+	// It returns 0 bytes when `buf` does not hold a complete frame yet, and an error when the stream is unusable.
+	Split(buf []byte) (packet []byte, n int, err error)
+
+	// Frame returns `packet`, after encryption, as it should be written to the wire
+	Frame(packet []byte) []byte
+}
+
+// legacyFramer is the default framer: every read is one frame whose first 4 bytes are an opaque header
+//
+// It leaves outgoing packets untouched, so headers are up to the encrypter, as in MapleStory-style protocols.
+type legacyFramer struct{}
+
+// legacyHeaderSize is the size of the header `legacyFramer` strips from each read
+const legacyHeaderSize = 4
+
+func (legacyFramer) Split(buf []byte) ([]byte, int, error) {
+	if len(buf) < legacyHeaderSize {
+		return nil, 0, nil
+	}
+
+	return buf[legacyHeaderSize:], len(buf), nil
+}
+
+func (legacyFramer) Frame(packet []byte) []byte {
+	return packet
+}
+
+// WithFramer returns a `SessionOption` which the Session constructor uses to modify its `framer` member
+//
+// Servers hand a framer to all of their sessions with `WithSessionOptions(WithFramer(framer))`. Without one, every
+// read is treated as a single packet behind a 4 byte header.
+func WithFramer(framer Framer) SessionOption {
+	return func(s *Session) {
+		s.framer = framer
+	}
+}
+
+// A LengthPrefixFramer frames packets behind a fixed-size length header
+//
+// It accumulates partial reads until a frame is complete and splits reads holding several frames.
+type LengthPrefixFramer struct {
+	headerSize     int              // Size of the length header in bytes: 1, 2, 4 or 8
+	order          binary.ByteOrder // Byte order of the length header
+	maxSize        int              // Largest accepted packet length, 1 MiB by default; 0 means no limit
+	includesHeader bool             // Whether the length counts the header bytes as well as the payload
+	offset         int              // Bytes preceding the length header, kept as the start of the packet
+	empty          EmptyFramePolicy // What happens to frames with an empty packet
+	stallTimeout   time.Duration    // Time allowed between reads of a partial frame; 0 leaves it to the idle timeout
+}
+
+// A FramerOption configures a `LengthPrefixFramer`
+type FramerOption func(*LengthPrefixFramer)
+
+// NewLengthPrefixFramer creates a `LengthPrefixFramer`, by default with a 2 byte little-endian length header
+func NewLengthPrefixFramer(options ...FramerOption) *LengthPrefixFramer {
+	f := &LengthPrefixFramer{
+		headerSize: 2,
+		order:      binary.LittleEndian,
+		maxSize:    defaultMaxFrameSize, // Never let a peer make us buffer gigabytes for one frame
+	}
+
+	// Call each option
+	for _, option := range options {
+		option(f)
+	}
+
+	return f
+}
+
+// WithHeaderSize returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its `headerSize` member
+//
+// `size` must be 1, 2, 4 or 8.
+func WithHeaderSize(size int) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.headerSize = size
+	}
+}
+
+// WithByteOrder returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its `order` member
+func WithByteOrder(order binary.ByteOrder) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.order = order
+	}
+}
+
+// WithMaxFrameSize returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its `maxSize` member
+//
+// The default is 1 MiB; 0 means no limit, which lets a peer make the server buffer as much as its header can express.
+func WithMaxFrameSize(size int) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.maxSize = size
+	}
+}
+
+// WithLengthIncludesHeader returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its `includesHeader` member
+//
+// Protocols disagree on whether the length counts the header; when it does, it also counts the `WithLengthOffset` bytes.
+func WithLengthIncludesHeader(includes bool) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.includesHeader = includes
+	}
+}
+
+// WithLengthOffset returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its `offset` member
+//
+// The length header then sits `offset` bytes into the frame, after a fixed field such as an opcode or magic number.
+// Those bytes stay at the start of the packet handed to handlers, and `Frame` expects them at the start of the packet
+// it is given, so only the length header is added and stripped.
+func WithLengthOffset(offset int) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.offset = offset
+	}
+}
+
+func (f *LengthPrefixFramer) Split(buf []byte) ([]byte, int, error) {
+	switch f.headerSize {
+	case 1, 2, 4, 8:
+	default:
+		return nil, 0, fmt.Errorf("tcpserve: unsupported length header size %d", f.headerSize)
+	}
+	if f.offset < 0 {
+		return nil, 0, fmt.Errorf("tcpserve: negative length header offset %d", f.offset)
+	}
+
+	start := f.offset + f.headerSize // Where the payload begins
+	if len(buf) < start {
+		return nil, 0, nil // Header is not complete yet
+	}
+
+	length := f.getLength(buf[f.offset:])
+	if f.includesHeader {
+		if length < uint64(start) {
+			return nil, 0, fmt.Errorf("tcpserve: frame length %d is shorter than its %d byte header", length, start)
+		}
+		length -= uint64(start) // Keep only the payload
+	}
+	if f.maxSize > 0 && length > uint64(f.maxSize) {
+		return nil, 0, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, length)
+	}
+	if length > uint64(math.MaxInt-start) {
+		return nil, 0, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, length) // The frame's end would overflow
+	}
+
+	end := uint64(start) + length
+	if uint64(len(buf)) < end {
+		return nil, 0, nil // Packet is not complete yet
+	}
+
+	if f.offset == 0 {
+		return buf[start:end], int(end), nil
+	}
+
+	packet := make([]byte, 0, uint64(f.offset)+length) // Leading bytes and payload are split by the length header
+	packet = append(packet, buf[:f.offset]...)
+	packet = append(packet, buf[start:end]...)
+
+	return packet, int(end), nil
+}
+
+func (f *LengthPrefixFramer) Frame(packet []byte) []byte {
+	offset := f.offset
+	if offset > len(packet) {
+		offset = len(packet) // Missing leading bytes are left zeroed
+	}
+	payload := packet[offset:]
+
+	frame := make([]byte, f.offset+f.headerSize+len(payload))
+	copy(frame, packet[:offset])
+
+	length := uint64(len(payload))
+	if f.includesHeader {
+		length += uint64(f.offset + f.headerSize)
+	}
+	f.putLength(frame[f.offset:], length)
+	copy(frame[f.offset+f.headerSize:], payload)
+
+	return frame
+}
+
+// getLength reads the length header at the start of `buf`
+func (f *LengthPrefixFramer) getLength(buf []byte) uint64 {
+	switch f.headerSize {
+	case 1:
+		return uint64(buf[0])
+	case 2:
+		return uint64(f.order.Uint16(buf))
+	case 4:
+		return uint64(f.order.Uint32(buf))
+	default:
+		return f.order.Uint64(buf)
+	}
+}
+
+// putLength writes `length` as the header at the start of `buf`
+func (f *LengthPrefixFramer) putLength(buf []byte, length uint64) {
+	switch f.headerSize {
+	case 1:
+		buf[0] = byte(length)
+	case 2:
+		f.order.PutUint16(buf, uint16(length))
+	case 4:
+		f.order.PutUint32(buf, uint32(length))
+	default:
+		f.order.PutUint64(buf, length)
+	}
+}
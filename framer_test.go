@@ -0,0 +1,178 @@
+package tcpserve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLengthPrefixFramerSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []FramerOption
+		buf     []byte
+		packet  []byte
+		size    int
+		err     error // Expected with errors.Is; any error if errAny
+		errAny  bool
+	}{
+		{name: "partial header", buf: []byte{3}},
+		{name: "partial payload", buf: []byte{3, 0, 'a', 'b'}},
+		{name: "complete", buf: []byte{3, 0, 'a', 'b', 'c'}, packet: []byte("abc"), size: 5},
+		{name: "coalesced", buf: []byte{1, 0, 'a', 2, 0, 'b', 'c'}, packet: []byte("a"), size: 3},
+		{name: "empty", buf: []byte{0, 0, 'x'}, packet: []byte{}, size: 2},
+		{
+			name:    "big endian 4 byte header",
+			options: []FramerOption{WithHeaderSize(4), WithByteOrder(binary.BigEndian)},
+			buf:     []byte{0, 0, 0, 2, 'h', 'i'},
+			packet:  []byte("hi"),
+			size:    6,
+		},
+		{
+			name:    "oversize",
+			options: []FramerOption{WithMaxFrameSize(2)},
+			buf:     []byte{3, 0, 'a', 'b', 'c'},
+			err:     ErrFrameTooLarge,
+		},
+		{
+			name:    "length includes header",
+			options: []FramerOption{WithLengthIncludesHeader(true)},
+			buf:     []byte{4, 0, 'h', 'i', 'x'},
+			packet:  []byte("hi"),
+			size:    4,
+		},
+		{
+			name:    "length shorter than header",
+			options: []FramerOption{WithLengthIncludesHeader(true)},
+			buf:     []byte{1, 0, 'h'},
+			errAny:  true,
+		},
+		{
+			name:    "offset",
+			options: []FramerOption{WithLengthOffset(1)},
+			buf:     []byte{0x7F, 2, 0, 'h', 'i'},
+			packet:  []byte{0x7F, 'h', 'i'},
+			size:    5,
+		},
+		{
+			name:    "offset with length including header",
+			options: []FramerOption{WithLengthOffset(1), WithLengthIncludesHeader(true)},
+			buf:     []byte{0x7F, 5, 0, 'h', 'i'},
+			packet:  []byte{0x7F, 'h', 'i'},
+			size:    5,
+		},
+		{
+			name:    "4 byte header over the default limit",
+			options: []FramerOption{WithHeaderSize(4)},
+			buf:     []byte{0xFF, 0xFF, 0xFF, 0xFF, 'a'},
+			err:     ErrFrameTooLarge,
+		},
+		{
+			name:    "4 byte header without limit",
+			options: []FramerOption{WithHeaderSize(4), WithMaxFrameSize(0)},
+			buf:     []byte{0xFF, 0xFF, 0xFF, 0xFF, 'a'},
+		},
+		{
+			name:    "overflowing 8 byte header",
+			options: []FramerOption{WithHeaderSize(8)},
+			buf:     []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 'a'},
+			err:     ErrFrameTooLarge,
+		},
+		{
+			name:    "overflowing 8 byte header without limit",
+			options: []FramerOption{WithHeaderSize(8), WithMaxFrameSize(math.MaxInt)},
+			buf:     []byte{0xF9, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F, 'a'},
+			err:     ErrFrameTooLarge,
+		},
+		{
+			name:    "overflowing 8 byte header including header",
+			options: []FramerOption{WithHeaderSize(8), WithLengthIncludesHeader(true), WithMaxFrameSize(math.MaxInt)},
+			buf:     []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 'a'},
+			err:     ErrFrameTooLarge,
+		},
+		{
+			name:    "unsupported header size",
+			options: []FramerOption{WithHeaderSize(3)},
+			buf:     []byte{1, 0, 0, 'a'},
+			errAny:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, size, err := NewLengthPrefixFramer(tt.options...).Split(tt.buf)
+			switch {
+			case tt.err != nil || tt.errAny:
+				if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+					t.Fatalf("Split() error = %v, want %v", err, tt.err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Split() unexpected error: %v", err)
+			}
+
+			if size != tt.size {
+				t.Errorf("Split() size = %d, want %d", size, tt.size)
+			}
+			if !bytes.Equal(packet, tt.packet) || (tt.packet == nil) != (packet == nil) {
+				t.Errorf("Split() packet = %q, want %q", packet, tt.packet)
+			}
+		})
+	}
+}
+
+func TestLengthPrefixFramerFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []FramerOption
+		packet  []byte
+		frame   []byte
+	}{
+		{name: "default", packet: []byte("abc"), frame: []byte{3, 0, 'a', 'b', 'c'}},
+		{name: "empty", packet: []byte{}, frame: []byte{0, 0}},
+		{
+			name:    "8 byte big endian header",
+			options: []FramerOption{WithHeaderSize(8), WithByteOrder(binary.BigEndian)},
+			packet:  []byte("a"),
+			frame:   []byte{0, 0, 0, 0, 0, 0, 0, 1, 'a'},
+		},
+		{
+			name:    "length includes header",
+			options: []FramerOption{WithLengthIncludesHeader(true)},
+			packet:  []byte("hi"),
+			frame:   []byte{4, 0, 'h', 'i'},
+		},
+		{
+			name:    "offset",
+			options: []FramerOption{WithLengthOffset(1)},
+			packet:  []byte{0x7F, 'h', 'i'},
+			frame:   []byte{0x7F, 2, 0, 'h', 'i'},
+		},
+		{
+			name:    "offset longer than packet",
+			options: []FramerOption{WithLengthOffset(2)},
+			packet:  []byte{0x7F},
+			frame:   []byte{0x7F, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewLengthPrefixFramer(tt.options...)
+			frame := f.Frame(tt.packet)
+			if !bytes.Equal(frame, tt.frame) {
+				t.Fatalf("Frame() = %v, want %v", frame, tt.frame)
+			}
+
+			if len(tt.packet) < f.offset {
+				return // Missing leading bytes don't survive the round trip
+			}
+			packet, size, err := f.Split(frame)
+			if err != nil || size != len(frame) || !bytes.Equal(packet, tt.packet) {
+				t.Fatalf("Split(Frame()) = %q, %d, %v; want %q, %d, nil", packet, size, err, tt.packet, len(frame))
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package tcpserve
+
+// groupMiddleware is the middleware attached to one group with `WithGroupMiddleware`
+type groupMiddleware struct {
+	group      string
+	middleware []PacketMiddleware
+}
+
+// WithGroupMiddleware returns a `ServerOption` which the Server constructor uses to modify its `groupMiddleware` member
+//
+// Packets from sessions that joined `group` pass through `middleware` after the global middleware, so rules such as
+// extra validation during a duel stay out of the global chain. A session in several groups goes through each group's
+// middleware in the order the groups were given to the server.
+func WithGroupMiddleware(group string, middleware ...PacketMiddleware) ServerOption {
+	return func(s *Server) {
+		s.groupMiddleware = append(s.groupMiddleware, groupMiddleware{group: group, middleware: middleware})
+	}
+}
+
+// JoinGroup adds the session to `group`
+func (s *Session) JoinGroup(group string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.groups == nil {
+		s.groups = make(map[string]struct{})
+	}
+	s.groups[group] = struct{}{}
+}
+
+// LeaveGroup removes the session from `group`
+func (s *Session) LeaveGroup(group string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	delete(s.groups, group)
+}
+
+// InGroup reports whether the session is in `group`
+func (s *Session) InGroup(group string) bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	_, ok := s.groups[group]
+
+	return ok
+}
+
+// dispatchGroups runs the packet through the middleware of the session's groups before dispatching it
+func (s *Server) dispatchGroups(session *Session, packet []byte) {
+	handler := HandlerFunc(s.dispatch)
+	for i := len(s.groupMiddleware) - 1; i >= 0; i-- {
+		g := s.groupMiddleware[i]
+		if !session.InGroup(g.group) {
+			continue
+		}
+		for j := len(g.middleware) - 1; j >= 0; j-- {
+			handler = g.middleware[j](handler)
+		}
+	}
+
+	handler(session, packet)
+}
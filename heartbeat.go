@@ -0,0 +1,72 @@
+package tcpserve
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrHeartbeatTimeout is reported to `onDisconnected` for sessions that did not answer a heartbeat in time
+var ErrHeartbeatTimeout = errors.New("tcpserve: heartbeat timed out")
+
+// WithHeartbeat returns a `ServerOption` which the Server constructor uses to modify its `heartbeat` member
+//
+// Every `interval`, each session is sent the packet returned by `buildPing`, through its encrypter. A session that
+// receives nothing within `timeout` of a ping is disconnected with `ErrHeartbeatTimeout`. Any packet counts as an
+// answer; pongs reach the packet handlers like every other packet.
+func WithHeartbeat(interval, timeout time.Duration, buildPing func() []byte) ServerOption {
+	return func(s *Server) {
+		s.heartbeat = &heartbeat{interval: interval, timeout: timeout, buildPing: buildPing}
+	}
+}
+
+// heartbeat describes the keepalive set up by `WithHeartbeat`
+type heartbeat struct {
+	interval  time.Duration
+	timeout   time.Duration
+	buildPing func() []byte
+}
+
+// startHeartbeat pings `session` until its context ends, closing its connection when a ping goes unanswered
+func (s *Server) startHeartbeat(session *Session) {
+	hb := s.heartbeat
+
+	go func() {
+		ticker := time.NewTicker(hb.interval)
+		defer ticker.Stop()
+
+		ctx := session.Context()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			sent := s.now()
+			if _, err := session.Write(hb.buildPing()); err != nil {
+				return // Connection is going away on its own
+			}
+
+			timer := time.NewTimer(hb.timeout)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if session.LastActive().Before(sent) {
+				s.logWarn("Heartbeat timed out", Field{"session", session.id})
+				atomic.StoreInt32(&session.heartbeatLost, 1)
+				session.Conn().Close() // Unblock the read loop
+				return
+			}
+		}
+	}()
+}
+
+// heartbeatTimedOut reports whether the session was closed for missing a heartbeat
+func (s *Session) heartbeatTimedOut() bool {
+	return atomic.LoadInt32(&s.heartbeatLost) == 1
+}
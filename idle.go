@@ -0,0 +1,51 @@
+package tcpserve
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrIdleTimeout is reported to `onDisconnected` for sessions closed by `WithIdleTimeout`
+var ErrIdleTimeout = errors.New("tcpserve: session idle timeout")
+
+// WithIdleTimeout returns a `ServerOption` which the Server constructor uses to modify its `idleTimeout` member
+//
+// Each session's read deadline is pushed back by `d` before every read, so a client that sends nothing for `d` is
+// disconnected with `ErrIdleTimeout` instead of holding its goroutine and registry slot forever.
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+// refreshDeadline pushes the read deadline back by the session's idle timeout, or by its partial frame timeout in
+// the middle of a frame, unless reading has been stopped
+func (s *Session) refreshDeadline() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	timeout := s.idleTimeout
+	if s.stallTimeout > 0 {
+		timeout = s.stallTimeout
+	}
+	if timeout > 0 && !s.readStopped {
+		s.Conn().SetReadDeadline(s.clock().Add(timeout))
+	}
+}
+
+// stopReading makes the session's pending and future reads fail, without being undone by `refreshDeadline`
+func (s *Session) stopReading() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.readStopped = true
+	s.Conn().SetReadDeadline(time.Now())
+}
+
+// isTimeout reports whether `err` is a deadline being exceeded
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
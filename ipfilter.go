@@ -0,0 +1,51 @@
+package tcpserve
+
+import (
+	"net"
+)
+
+// WithIPFilter returns a `ServerOption` which the Server constructor uses to modify its `addrFilter` member
+//
+// Connections from an IP in `deny` are rejected, as are connections from outside `allow` unless it is empty. The
+// filter runs right after accepting, before any other admission check or handshake work.
+func WithIPFilter(allow []net.IPNet, deny []net.IPNet) ServerOption {
+	return WithAddrFilter(func(addr net.Addr) bool {
+		ip := net.ParseIP(remoteIP(addr))
+		for _, network := range deny {
+			if ip != nil && network.Contains(ip) {
+				return false
+			}
+		}
+		if len(allow) == 0 {
+			return true
+		}
+		for _, network := range allow {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// WithAddrFilter returns a `ServerOption` which the Server constructor uses to modify its `addrFilter` member
+//
+// Connections whose remote address `filter` returns false for are closed right after accepting, like `WithIPFilter`
+// but with the decision up to the caller, for instance backed by a ban list that changes at runtime.
+func WithAddrFilter(filter func(net.Addr) bool) ServerOption {
+	return func(s *Server) {
+		s.addrFilter = filter
+	}
+}
+
+// filterAddr applies the address filter to `conn`, returning false if it must be rejected
+func (s *Server) filterAddr(conn net.Conn) bool {
+	if s.addrFilter == nil || s.addrFilter(conn.RemoteAddr()) {
+		return true
+	}
+
+	s.logWarn("Rejecting filtered connection", Field{"remote", conn.RemoteAddr()})
+
+	return false
+}
@@ -0,0 +1,73 @@
+package tcpserve
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// WithSimulatedLatency returns a `ServerOption` which the Server constructor uses to modify its `latency` member
+//
+// Meant for local development only: every read and write on accepted connections is delayed by a latency that
+// starts halfway between `min` and `max` and drifts by up to `jitter` per operation, staying within the bounds.
+func WithSimulatedLatency(min, max, jitter time.Duration) ServerOption {
+	return func(s *Server) {
+		s.latency = &simulatedLatency{min: min, max: max, jitter: jitter}
+	}
+}
+
+// simulatedLatency describes the delays applied by `WithSimulatedLatency`
+type simulatedLatency struct {
+	min, max, jitter time.Duration
+}
+
+// latencyConn delays every read and write of the connection it wraps
+type latencyConn struct {
+	net.Conn
+	latency *simulatedLatency
+	mu      sync.Mutex
+	current time.Duration // Latency of the previous operation
+}
+
+// wrap returns `conn` with the simulated latency applied
+func (l *simulatedLatency) wrap(conn net.Conn) net.Conn {
+	return &latencyConn{
+		Conn:    conn,
+		latency: l,
+		current: l.min + (l.max-l.min)/2,
+	}
+}
+
+// delay sleeps for the next latency sample
+func (c *latencyConn) delay() {
+	c.mu.Lock()
+	if c.latency.jitter > 0 {
+		c.current += time.Duration(rand.Int63n(int64(2*c.latency.jitter)+1)) - c.latency.jitter // Drift either way
+	}
+	if c.current < c.latency.min {
+		c.current = c.latency.min
+	}
+	if c.current > c.latency.max {
+		c.current = c.latency.max
+	}
+	d := c.current
+	c.mu.Unlock()
+
+	time.Sleep(d)
+}
+
+func (c *latencyConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.delay() // Delay delivery of what arrived, not the wait for it
+	}
+
+	return n, err
+}
+
+func (c *latencyConn) Write(b []byte) (int, error) {
+	c.delay()
+
+	return c.Conn.Write(b)
+}
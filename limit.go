@@ -0,0 +1,89 @@
+package tcpserve
+
+import (
+	"net"
+)
+
+// A FullPolicy decides what happens to new connections once `WithMaxConnections` is reached
+type FullPolicy int
+
+const (
+	RejectWhenFull FullPolicy = iota // Close new connections, after sending the server full packet if one is set
+	QueueWhenFull                    // Hold the next connection until a slot frees up, leaving later ones in the backlog
+)
+
+// WithMaxConnections returns a `ServerOption` which the Server constructor uses to modify its `limit` member
+//
+// At most `n` connections are served at once, counting connections still going through admission checks.
+func WithMaxConnections(n int, whenFull FullPolicy) ServerOption {
+	return func(s *Server) {
+		s.limit = &connLimit{max: n, queue: whenFull == QueueWhenFull}
+	}
+}
+
+// WithServerFullPacket returns a `ServerOption` which the Server constructor uses to modify its `fullPacket` member
+//
+// Connections rejected by `WithMaxConnections` get `packet`, through the session options' encrypter and framer,
+// before being closed.
+func WithServerFullPacket(packet []byte) ServerOption {
+	return func(s *Server) {
+		s.fullPacket = packet
+	}
+}
+
+// connLimit hands out the connection slots of `WithMaxConnections`
+type connLimit struct {
+	max   int
+	queue bool          // Whether to wait for a slot instead of rejecting
+	slots chan struct{} // Holds a value for every slot in use, created by `Start`
+}
+
+// start makes the limit usable
+func (l *connLimit) start() {
+	if l != nil {
+		l.slots = make(chan struct{}, l.max)
+	}
+}
+
+// claim takes a slot for an accepted connection, waiting for one when queueing, and reports false if the connection
+// must be turned away
+func (l *connLimit) claim(done <-chan struct{}) bool {
+	if l == nil {
+		return true
+	}
+
+	if l.queue {
+		select {
+		case l.slots <- struct{}{}:
+			return true
+		case <-done:
+			return false // Stopped while the connection was waiting
+		}
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release gives back the slot of a connection that has been closed
+func (l *connLimit) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// turnAway tells a connection rejected for lack of slots that the server is full and closes it
+func (s *Server) turnAway(conn net.Conn) {
+	defer s.wg.Done() // Decrement wait group for connection
+
+	s.logWarn("Server full, rejecting connection", Field{"remote", conn.RemoteAddr()})
+	if s.fullPacket != nil {
+		options := append([]SessionOption{}, s.sessionOptions...)
+		NewSession(append(options, WithConn(conn))...).Write(s.fullPacket) // Best effort, it is closed either way
+	}
+	conn.Close()
+}
@@ -0,0 +1,227 @@
+package tcpserve
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listenFdsStart is the first file descriptor passed by the LISTEN_FDS convention (after stdin, stdout and stderr)
+const listenFdsStart = 3
+
+// WithInheritedListener returns a `ServerOption` which the Server constructor uses to modify its `inherited` member
+//
+// Instead of binding its port, the server serves on a listening socket inherited from its parent process using the
+// LISTEN_FDS convention (systemd socket activation and similar handoff tools). `name` is matched against the
+// LISTEN_FDNAMES entries first, then against the file descriptor number itself (e.g. "3").
+func WithInheritedListener(name string) ServerOption {
+	return func(s *Server) {
+		s.inherited = name
+	}
+}
+
+// An inheritedFd is a file descriptor passed by the LISTEN_FDS convention
+type inheritedFd struct {
+	name string   // Its LISTEN_FDNAMES entry, if any
+	file *os.File // Kept open for the life of the process, so the number is never reused
+}
+
+var (
+	inheritOnce sync.Once
+	inheritFds  []inheritedFd // Passed file descriptors, in order
+	inheritErr  error         // Why no file descriptors could be taken over, if none were
+)
+
+// inheritFiles takes over the file descriptors passed to this process, once, and unsets the LISTEN_FDS variables so
+// that child processes don't believe they were passed to them too
+func inheritFiles() ([]inheritedFd, error) {
+	inheritOnce.Do(func() {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+
+		if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+			inheritErr = fmt.Errorf("LISTEN_PID %s does not match this process", pid)
+			return
+		}
+
+		count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || count < 1 {
+			inheritErr = errors.New("no file descriptors were passed (LISTEN_FDS unset)")
+			return
+		}
+
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		for i := 0; i < count; i++ {
+			fd := inheritedFd{file: os.NewFile(uintptr(listenFdsStart+i), "LISTEN_FD_"+strconv.Itoa(listenFdsStart+i))}
+			if i < len(names) {
+				fd.name = names[i]
+			}
+			inheritFds = append(inheritFds, fd)
+		}
+	})
+
+	return inheritFds, inheritErr
+}
+
+// inheritedListener looks up the listening socket called `name` among the file descriptors passed to this process
+//
+// Each call returns a new listener on a duplicate of the descriptor, so restarted and cloned servers can call it again.
+func inheritedListener(name string) (net.Listener, error) {
+	fds, err := inheritFiles()
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener %q: %w", name, err)
+	}
+
+	for i, fd := range fds {
+		if fd.name != name && strconv.Itoa(listenFdsStart+i) != name {
+			continue
+		}
+
+		ln, err := net.FileListener(fd.file) // Duplicates the descriptor, which stays open for later calls
+		if err != nil {
+			return nil, fmt.Errorf("inherited listener %q: %w", name, err)
+		}
+
+		return ln, nil
+	}
+
+	return nil, fmt.Errorf("inherited listener %q: not found among %d passed file descriptors", name, len(fds))
+}
+
+// WithAddr returns a `ServerOption` which the Server constructor uses to modify its `addrs` member
+//
+// The server listens on `addr` (such as "127.0.0.1:8484" or ":8485") instead of its port. Repeat the option to
+// accept on several addresses at once; connections from all of them share the same sessions and handlers.
+func WithAddr(addr string) ServerOption {
+	return func(s *Server) {
+		s.addrs = append(s.addrs, addr)
+	}
+}
+
+// WithListener returns a `ServerOption` which the Server constructor uses to modify its `injected` member
+//
+// The server accepts on `ln`, created by the caller (a systemd socket, a `tls.Listener`, an in-memory listener for
+// tests), instead of binding an address itself. Repeat the option to serve several listeners. The server closes the
+// listeners when it stops; TLS and WebSocket options are still layered on top when set.
+func WithListener(ln net.Listener) ServerOption {
+	return func(s *Server) {
+		s.injected = append(s.injected, ln)
+	}
+}
+
+// listen creates the server's listeners, with TLS and WebSocket layered on top as configured
+func (s *Server) listen() ([]net.Listener, error) {
+	lns, err := s.plainListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ln := range lns {
+		if s.tlsConfig != nil {
+			ln = tls.NewListener(ln, s.tlsConfig)
+		}
+		if s.webSocket {
+			ln = NewWebSocketListener(ln, s.webSocketPath) // WebSocket runs over TLS, not the other way around
+		}
+		lns[i] = ln
+	}
+
+	return lns, nil
+}
+
+// plainListeners returns the injected listeners, or binds one listener per listening address
+func (s *Server) plainListeners() ([]net.Listener, error) {
+	if len(s.injected) > 0 {
+		return append([]net.Listener(nil), s.injected...), nil
+	}
+
+	var lns []net.Listener
+	for _, addr := range s.listenAddrs() {
+		ln, err := s.bind(addr)
+		if err != nil {
+			closeListeners(lns)
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// listenAddrs returns the addresses the server listens on
+func (s *Server) listenAddrs() []string {
+	switch {
+	case s.inherited != "":
+		return []string{s.inherited}
+	case s.unixPath != "":
+		return []string{s.unixPath}
+	case len(s.addrs) > 0:
+		return s.addrs
+	default:
+		return []string{fmt.Sprintf(":%d", s.port)}
+	}
+}
+
+// closeListeners closes every listener of `lns`, returning the first error
+func closeListeners(lns []net.Listener) (err error) {
+	for _, ln := range lns {
+		if closeErr := ln.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
+
+// bind creates a plain listener on `addr`, retrying the bind as configured by `WithBindRetry`
+func (s *Server) bind(addr string) (ln net.Listener, err error) {
+	if s.inherited != "" {
+		return inheritedListener(s.inherited) // Inherited sockets are already bound
+	}
+
+	for attempt := 0; ; attempt++ {
+		ln, err = s.bindAddr(addr)
+		if err == nil || attempt >= s.bindRetries {
+			return
+		}
+
+		s.logWarn("Could not bind, retrying", Field{"addr", addr}, Field{"attempt", attempt + 1}, Field{"attempts", s.bindRetries + 1},
+			Field{"delay", s.bindDelay}, Field{"error", err})
+		timer := time.NewTimer(s.bindDelay)
+		select {
+		case <-timer.C:
+		case <-s.done:
+			timer.Stop()
+			return nil, ErrServerClosed // Stopped while waiting to retry
+		}
+	}
+}
+
+// bindAddr creates a listener on `addr` for the server's transport
+func (s *Server) bindAddr(addr string) (net.Listener, error) {
+	if s.transport != nil {
+		return s.transport.Listen(addr)
+	}
+	if s.unixPath != "" {
+		return s.listenUnix()
+	}
+	if !s.udp {
+		return net.Listen("tcp", addr)
+	}
+
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUDPListener(pc, s.udpIdle), nil
+}
@@ -0,0 +1,32 @@
+package tcpserve
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStopDuringBindRetry(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer taken.Close()
+
+	s := NewServer(WithAddr(taken.Addr().String()), WithBindRetry(10, time.Hour), WithOnPacket(func(*Session, []byte) {}))
+
+	stop := time.AfterFunc(20*time.Millisecond, func() { s.Stop() }) // Once the first bind failed
+	defer stop.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	began := time.Now()
+	if err := s.Start(&wg); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Start() error = %v, want %v", err, ErrServerClosed)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("Start() returned after %s, waiting out the bind retry delay after Stop", elapsed)
+	}
+}
@@ -0,0 +1,143 @@
+package tcpserve
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A LogLevel is the severity of a log event
+type LogLevel int
+
+// Log levels, from least to most severe
+const (
+	LevelDebug LogLevel = iota // Per-packet events, for development
+	LevelInfo                  // Routine events such as connections opening and closing
+	LevelWarn                  // Connections turned away and recoverable trouble
+	LevelError                 // Failures, reported to the error logger
+)
+
+// String returns the name of the level
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// WithLogLevel returns a `ServerOption` which the Server constructor uses to modify its `logLevel` member
+//
+// Events below `level` are discarded before they are formatted. The default, `LevelInfo`, keeps per-packet debug
+// events quiet.
+func WithLogLevel(level LogLevel) ServerOption {
+	return func(s *Server) {
+		s.logLevel = level
+	}
+}
+
+// A Field is a structured value attached to a log event, such as the session ID or remote address
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// A StructuredLogger receives the server's log events along with their fields
+//
+// `Log` may be called from many goroutines at once.
+type StructuredLogger interface {
+	Log(level LogLevel, msg string, fields ...Field)
+}
+
+// WithStructuredLogger returns a `ServerOption` which the Server constructor uses to modify its `structured` member
+//
+// Log events then go to `logger` with their fields, such as "session", "remote", "addr" and "error", instead of
+// being formatted for the `WithLoggers` functions.
+func WithStructuredLogger(logger StructuredLogger) ServerOption {
+	return func(s *Server) {
+		s.structured = logger
+	}
+}
+
+// debugging reports whether debug events are logged, so callers can skip building their fields
+func (s *Server) debugging() bool {
+	return s.logLevel <= LevelDebug
+}
+
+// logDebug reports a per-packet event
+func (s *Server) logDebug(msg string, fields ...Field) {
+	s.logEvent(LevelDebug, msg, fields)
+}
+
+// logInfo reports a routine event
+func (s *Server) logInfo(msg string, fields ...Field) {
+	s.logEvent(LevelInfo, msg, fields)
+}
+
+// logWarn reports a connection turned away or trouble the server recovers from
+func (s *Server) logWarn(msg string, fields ...Field) {
+	s.logEvent(LevelWarn, msg, fields)
+}
+
+// logError reports a failure
+func (s *Server) logError(msg string, fields ...Field) {
+	s.logEvent(LevelError, msg, fields)
+}
+
+// logPacket reports a decoded packet at debug level, with its opcode when the server has a router
+func (s *Server) logPacket(session *Session, wireSize int, packet []byte) {
+	fields := []Field{{"session", session.id}, {"bytes", wireSize}}
+	if s.router != nil {
+		if opcode, ok := s.router.Opcode(packet); ok {
+			fields = append(fields, Field{"opcode", fmt.Sprintf("0x%X", uint16(opcode))})
+		}
+	}
+
+	s.logDebug("Packet received", fields...)
+}
+
+// logEvent hands an event to the structured logger, or formats it for the `WithLoggers` functions
+//
+// Text loggers get debug and warn events prefixed with their level.
+func (s *Server) logEvent(level LogLevel, msg string, fields []Field) {
+	if level < s.logLevel {
+		return
+	}
+	if s.structured != nil {
+		s.structured.Log(level, msg, fields...)
+		return
+	}
+
+	line := formatEvent(msg, fields)
+	switch level {
+	case LevelDebug:
+		s.log(fmt.Sprint("[Debug]", line))
+	case LevelWarn:
+		s.log(fmt.Sprint("[Warn]", line))
+	case LevelError:
+		s.errLog(line)
+	default:
+		s.log(line)
+	}
+}
+
+// formatEvent renders `msg` followed by its fields as key=value pairs
+func formatEvent(msg string, fields []Field) string {
+	if len(fields) == 0 {
+		return msg
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, field := range fields {
+		fmt.Fprintf(&b, " %s=%v", field.Key, field.Value)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package tcpserve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithLoggersRoutesErrors(t *testing.T) {
+	var infos, errs []string
+	info := func(msg string) { infos = append(infos, msg) }
+	errLog := func(msg string) { errs = append(errs, msg) }
+
+	s := NewServer(WithLoggers(info, errLog))
+	s.logInfo("routine")
+	s.logError("failure")
+	if len(infos) != 1 || len(errs) != 1 || errs[0] != "failure" {
+		t.Errorf("logged info %q and errors %q, want one each", infos, errs)
+	}
+
+	infos = nil
+	s = NewServer(WithLoggers(info, nil))
+	s.logError("failure")
+	if len(infos) != 1 || infos[0] != "[Error]failure" {
+		t.Errorf("logged %q, want the error prefixed on the logger", infos)
+	}
+}
+
+func TestDefaultLoggersDiscard(t *testing.T) {
+	s := NewServer() // Must not need WithLoggers to log
+	s.logInfo("routine")
+	s.logError("failure")
+}
+
+func TestLogEveryFields(t *testing.T) {
+	var lines []string
+	session := NewSession(WithId(7), WithLogger(func(msg string) { lines = append(lines, msg) }))
+
+	session.LogEvery("key", time.Hour, LevelWarn, "bad packet", Field{"opcode", 3})
+	session.LogEvery("key", time.Hour, LevelWarn, "bad packet", Field{"opcode", 3}) // Suppressed
+
+	session.logLimits["key"].last = time.Time{} // End the period
+	session.LogEvery("key", time.Hour, LevelWarn, "bad packet", Field{"opcode", 3})
+
+	want := []string{"[Warn]bad packet session=7 opcode=3", "[Warn]bad packet session=7 opcode=3 suppressed=1"}
+	if len(lines) != len(want) || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("logged %q, want %q", lines, want)
+	}
+}
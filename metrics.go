@@ -0,0 +1,46 @@
+package tcpserve
+
+import "time"
+
+// Error kinds reported to `Metrics.Error`
+const (
+	ErrorAccept  = "accept"  // Accepting a connection failed
+	ErrorRead    = "read"    // A session's connection failed while reading
+	ErrorWrite   = "write"   // A write to a session's connection failed
+	ErrorVersion = "version" // A session was closed by the version gate
+)
+
+// Metrics receives the server's operational measurements as they happen
+//
+// tcpserve stays free of dependencies, so it does not register collectors itself: implement `Metrics` on top of the
+// monitoring client of your choice. For Prometheus, the github.com/matthieutran/tcpserve/prometheus module does, with
+// `ConnectionOpened` and `ConnectionClosed` mapped to a gauge, the `Accepted`, packet and `Error` calls to counters (the
+// error kind as a label) and `HandlerDuration` to a histogram.
+// Every method may be called from many goroutines at once and must not block.
+type Metrics interface {
+	Accepted()                       // A connection was accepted, before admission checks
+	ConnectionOpened()               // A connection became a session
+	ConnectionClosed()               // A session ended
+	PacketReceived(bytes int)        // A frame of `bytes` bytes was read
+	PacketSent(bytes int)            // A write of `bytes` bytes was handed to a connection
+	HandlerDuration(d time.Duration) // A packet handler ran for `d`
+	Error(kind string)               // Something went wrong; `kind` is one of the `Error` constants
+}
+
+// WithMetrics returns a `ServerOption` which the Server constructor uses to modify its `metrics` member
+func WithMetrics(metrics Metrics) ServerOption {
+	return func(s *Server) {
+		s.metrics = metrics
+	}
+}
+
+// nopMetrics discards every measurement, used unless `WithMetrics` is
+type nopMetrics struct{}
+
+func (nopMetrics) Accepted()                     {}
+func (nopMetrics) ConnectionOpened()             {}
+func (nopMetrics) ConnectionClosed()             {}
+func (nopMetrics) PacketReceived(int)            {}
+func (nopMetrics) PacketSent(int)                {}
+func (nopMetrics) HandlerDuration(time.Duration) {}
+func (nopMetrics) Error(string)                  {}
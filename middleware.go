@@ -0,0 +1,41 @@
+package tcpserve
+
+// A PacketMiddleware wraps the next packet handler with cross-cutting behavior (logging, auth, rate limiting)
+//
+// It may inspect or modify the packet, call `next` zero or more times, and act after `next` returns.
+type PacketMiddleware func(next HandlerFunc) HandlerFunc
+
+// WithMiddleware returns a `ServerOption` which the Server constructor uses to modify its `middleware` member
+//
+// Decoded packets pass through the middleware before reaching the router or `onPacket`. The first middleware given
+// is the outermost, and repeated options append to the chain.
+func WithMiddleware(middleware ...PacketMiddleware) ServerOption {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// buildHandler composes the middleware chain around the server's packet dispatch
+func (s *Server) buildHandler() HandlerFunc {
+	handler := HandlerFunc(s.dispatch)
+	if len(s.groupMiddleware) > 0 {
+		handler = s.dispatchGroups // Group middleware runs innermost
+	}
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// dispatch hands a decoded packet to the router or `onPacket`
+func (s *Server) dispatch(session *Session, packet []byte) {
+	if s.router != nil {
+		s.route(session, packet) // Dispatch by opcode
+		return
+	}
+
+	if s.onPacket != nil {
+		s.onPacket(session, packet) // Send event to the outside
+	}
+}
@@ -0,0 +1,44 @@
+package tcpserve
+
+import (
+	"errors"
+)
+
+// ErrNoKeyResolver is returned by `WriteToKey` when the server has no user key resolver
+var ErrNoKeyResolver = errors.New("tcpserve: no user key resolver set")
+
+// WithOfflineDelivery returns a `ServerOption` which the Server constructor uses to modify its `resolveKey` and `storeOffline` members
+//
+// `resolve` maps an application user key (account, character) to its connected session. When `WriteToKey` targets a
+// key that is not connected, the message is handed to `store` so the application can persist it for later delivery.
+func WithOfflineDelivery(resolve func(key string) (*Session, bool), store func(key string, message []byte)) ServerOption {
+	return func(s *Server) {
+		s.resolveKey = resolve
+		s.storeOffline = store
+	}
+}
+
+// WriteToKey encrypts and sends `message` to the session of user `key`, or hands it to the offline store if the user
+// is not connected
+//
+// It reports whether the message was delivered live. Without an offline store, messages to disconnected users are
+// dropped.
+func (s *Server) WriteToKey(key string, message []byte) (bool, error) {
+	if s.resolveKey == nil {
+		return false, ErrNoKeyResolver
+	}
+
+	if session, ok := s.resolveKey(key); ok && session != nil {
+		if _, err := session.Write(message); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if s.storeOffline != nil {
+		s.storeOffline(key, message)
+	}
+
+	return false, nil
+}
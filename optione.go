@@ -0,0 +1,107 @@
+package tcpserve
+
+import (
+	"fmt"
+	"net"
+)
+
+// A ServerOptionE configures a `Server` like a `ServerOption`, but can fail, see `NewServerE`
+type ServerOptionE func(*Server) error
+
+// NewServerE creates a server like `NewServer`, returning every configuration problem at once instead of leaving
+// them to `Start`
+//
+// The errors of the options themselves, such as unreadable TLS files or malformed CIDRs, come first, followed by
+// those `Validate` finds in the option values. A missing packet handler is not reported yet, as `Events` may stand
+// in for one; `Start` still checks it. The error is an `OptionErrors`. Plain options mix in through `Infallible`.
+//
+// Servers cloned with `CloneConfig` rerun the options, but have nowhere to report their errors.
+func NewServerE(options ...ServerOptionE) (*Server, error) {
+	var errs OptionErrors
+	constructed := false
+
+	wrapped := make([]ServerOption, len(options))
+	for i, option := range options {
+		option := option
+		wrapped[i] = func(s *Server) {
+			if err := option(s); err != nil && !constructed {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	s := NewServer(wrapped...)
+	constructed = true
+	errs = append(errs, s.checkOptions()...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return s, nil
+}
+
+// Infallible returns a `ServerOptionE` applying `option`, which never fails
+func Infallible(option ServerOption) ServerOptionE {
+	return func(s *Server) error {
+		option(s)
+		return nil
+	}
+}
+
+// WithTLSFiles returns a `ServerOptionE` which the Server constructor uses to modify its `tlsConfig` member
+//
+// It loads the PEM encoded certificate and key pair like `LoadTLSConfig`, failing if they can't be read or parsed.
+func WithTLSFiles(certFile, keyFile string) ServerOptionE {
+	return func(s *Server) error {
+		config, err := LoadTLSConfig(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("WithTLSFiles: %w", err)
+		}
+		s.tlsConfig = config
+
+		return nil
+	}
+}
+
+// WithIPFilterCIDRs returns a `ServerOptionE` which the Server constructor uses to modify its `addrFilter` member
+//
+// It filters like `WithIPFilter`, with the networks written in CIDR notation ("10.0.0.0/8"); a bare IP stands for
+// itself alone. It fails on the first entry that is neither.
+func WithIPFilterCIDRs(allow []string, deny []string) ServerOptionE {
+	return func(s *Server) error {
+		allowNets, err := parseNetworks(allow)
+		if err != nil {
+			return fmt.Errorf("WithIPFilterCIDRs allow list: %w", err)
+		}
+		denyNets, err := parseNetworks(deny)
+		if err != nil {
+			return fmt.Errorf("WithIPFilterCIDRs deny list: %w", err)
+		}
+		WithIPFilter(allowNets, denyNets)(s)
+
+		return nil
+	}
+}
+
+// parseNetworks parses CIDR networks, turning bare IPs into single-address networks
+func parseNetworks(entries []string) ([]net.IPNet, error) {
+	networks := make([]net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 8*net.IPv4len
+			}
+			networks = append(networks, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", entry)
+		}
+		networks = append(networks, *network)
+	}
+
+	return networks, nil
+}
@@ -0,0 +1,112 @@
+package tcpserve
+
+import (
+	"sync"
+	"time"
+)
+
+// Peaks holds the server's high-water marks since it started or since the last `ResetPeaks`
+type Peaks struct {
+	Since         time.Time // When tracking started
+	Connections   int       // Most concurrent connections
+	ConnectionsAt time.Time // When `Connections` was reached
+	PacketRate    int       // Most packets received within a single second
+	PacketRateAt  time.Time // When `PacketRate` was reached
+	QueueDepth    int       // Most packets waiting for the worker pool at once, see `WithWorkerPool`
+	QueueDepthAt  time.Time // When `QueueDepth` was reached
+}
+
+// peakTracker keeps the server's high-water marks up to date
+type peakTracker struct {
+	mu          sync.Mutex
+	peaks       Peaks
+	connections int   // Current number of connections
+	second      int64 // Unix second the packet count belongs to
+	packets     int   // Packets received during `second`
+	queued      int   // Packets currently waiting for the worker pool
+}
+
+// connected records a new connection, made at `now`
+func (p *peakTracker) connected(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connections += 1
+	if p.connections > p.peaks.Connections {
+		p.peaks.Connections = p.connections
+		p.peaks.ConnectionsAt = now
+	}
+}
+
+// disconnected records a closed connection
+func (p *peakTracker) disconnected() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connections -= 1
+}
+
+// queue records a packet handed to the worker pool at `now`
+func (p *peakTracker) queue(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queued += 1
+	if p.queued > p.peaks.QueueDepth {
+		p.peaks.QueueDepth = p.queued
+		p.peaks.QueueDepthAt = now
+	}
+}
+
+// dequeue records a worker picking up a queued packet
+func (p *peakTracker) dequeue() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queued -= 1
+}
+
+// packet records a packet received at `now`
+func (p *peakTracker) packet(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if second := now.Unix(); second != p.second {
+		p.second = second // Start counting a new second
+		p.packets = 0
+	}
+	p.packets += 1
+
+	if p.packets > p.peaks.PacketRate {
+		p.peaks.PacketRate = p.packets
+		p.peaks.PacketRateAt = now
+	}
+}
+
+// reset starts tracking afresh from the state at `now`
+func (p *peakTracker) reset(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.peaks = Peaks{
+		Since:         now,
+		Connections:   p.connections,
+		ConnectionsAt: now,
+		QueueDepth:    p.queued,
+		QueueDepthAt:  now,
+	}
+	p.packets = 0
+}
+
+// Peaks returns the server's high-water marks
+func (s *Server) Peaks() Peaks {
+	s.peaks.mu.Lock()
+	defer s.peaks.mu.Unlock()
+
+	return s.peaks.peaks
+}
+
+// ResetPeaks discards the high-water marks recorded so far and starts tracking from the current load
+func (s *Server) ResetPeaks() {
+	s.peaks.reset(s.now())
+}
@@ -0,0 +1,45 @@
+package tcpserve
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPeaksQueueDepth(t *testing.T) {
+	var p peakTracker
+	start := time.Unix(1000, 0)
+	p.reset(start)
+
+	p.queue(start.Add(1 * time.Second))
+	p.queue(start.Add(2 * time.Second))
+	p.dequeue()
+	p.queue(start.Add(3 * time.Second)) // Back at 2, not a new high
+	if got := p.peaks; got.QueueDepth != 2 || !got.QueueDepthAt.Equal(start.Add(2*time.Second)) {
+		t.Errorf("queue depth peak = %d at %s, want 2 at %s", got.QueueDepth, got.QueueDepthAt, start.Add(2*time.Second))
+	}
+
+	p.dequeue()
+	p.reset(start.Add(4 * time.Second)) // One packet still queued
+	if got := p.peaks; got.QueueDepth != 1 || !got.QueueDepthAt.Equal(start.Add(4*time.Second)) {
+		t.Errorf("queue depth peak after reset = %d at %s, want 1", got.QueueDepth, got.QueueDepthAt)
+	}
+}
+
+func TestResetPeaksDuringStart(t *testing.T) {
+	done := make(chan struct{})
+	s := NewServer(WithAddr("127.0.0.1:0"), WithOnPacket(func(*Session, []byte) {}), WithCoarseClock(time.Millisecond))
+	go func() {
+		defer close(done)
+		for s.Addr() == nil {
+			s.ResetPeaks() // Reads the clock `Start` is setting up
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.Start(&wg)
+	<-done
+	s.Stop()
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+package tcpserve
+
+// A Plugin packages a feature (metrics, anti-cheat, geo-blocking) that hooks into the server's lifecycle
+type Plugin interface {
+	Init(s *Server) error            // Called once when the plugin is registered
+	OnSessionStart(session *Session) // Called for every new session, before `onConnected`
+	OnSessionEnd(session *Session)   // Called for every session once its connection has closed
+	Shutdown()                       // Called once when the server stops
+}
+
+// Register initializes `plugin` and attaches it to the server's lifecycle
+//
+// Plugins must be registered before `Start`. If `Init` fails the plugin is not attached and its error is returned.
+// Plugins are shut down in the reverse order of registration.
+func (s *Server) Register(plugin Plugin) error {
+	if err := plugin.Init(s); err != nil {
+		return err
+	}
+	s.plugins = append(s.plugins, plugin)
+
+	return nil
+}
+
+// startPlugins notifies every plugin of a new session
+func (s *Server) startPlugins(session *Session) {
+	for _, plugin := range s.plugins {
+		plugin.OnSessionStart(session)
+	}
+}
+
+// endPlugins notifies every plugin of a closed session
+func (s *Server) endPlugins(session *Session) {
+	for _, plugin := range s.plugins {
+		plugin.OnSessionEnd(session)
+	}
+}
+
+// shutdownPlugins shuts every plugin down, last registered first
+func (s *Server) shutdownPlugins() {
+	for i := len(s.plugins) - 1; i >= 0; i-- {
+		s.plugins[i].Shutdown()
+	}
+}
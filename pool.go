@@ -0,0 +1,53 @@
+package tcpserve
+
+import (
+	"sync"
+)
+
+// An AppendCodec performs operations on `src` and appends the result to `dst`, returning the extended slice
+//
+// Unlike a `Codec`, it lets the caller decide where the output lives, so the session can encrypt into pooled buffers
+// instead of allocating a new slice for every outbound packet.
+type AppendCodec func(dst, src []byte) []byte
+
+// writeBufferSize is the initial capacity of pooled write buffers
+const writeBufferSize = 2048
+
+// writeBuffers holds the buffers `AppendCodec` encrypters write into
+var writeBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, writeBufferSize)
+		return &buf
+	},
+}
+
+// WithAppendEncrypter returns a `SessionOption` which the Session constructor uses to modify its `appendEncrypt` member
+//
+// When set, `Write` encrypts with `encrypter` into a pooled buffer instead of calling the session's `Codec`.
+func WithAppendEncrypter(encrypter AppendCodec) SessionOption {
+	return func(s *Session) {
+		s.appendEncrypt = encrypter
+	}
+}
+
+// SetAppendEncrypter replaces the session's `AppendCodec` encrypter; nil falls back to the `Codec` encrypter
+func (s *Session) SetAppendEncrypter(encrypter AppendCodec) {
+	s.codecMu.Lock()
+	defer s.codecMu.Unlock()
+
+	s.appendEncrypt = encrypter
+}
+
+// writePooled encrypts `data` into a pooled buffer with the session's `AppendCodec` and sends it; the caller must
+// hold `codecMu`
+func (s *Session) writePooled(data []byte) (int, error) {
+	buf := writeBuffers.Get().(*[]byte)
+
+	res := s.appendEncrypt((*buf)[:0], data)
+	n, err := s.WriteRaw(s.framer.Frame(res))
+
+	*buf = res[:0] // Keep any growth for the next packet
+	writeBuffers.Put(buf)
+
+	return n, err
+}
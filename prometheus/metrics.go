@@ -0,0 +1,157 @@
+// Package prometheus implements `tcpserve.Metrics` with Prometheus collectors
+//
+// It lives in its own module so that tcpserve itself stays free of dependencies.
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matthieutran/tcpserve"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// namespace prefixes the name of every collector
+const namespace = "tcpserve"
+
+// Metrics reports a server's measurements to Prometheus collectors
+type Metrics struct {
+	accepted    prom.Counter     // Connections accepted, before admission checks
+	connections prom.Gauge       // Current sessions
+	packetsIn   prom.Counter     // Frames read
+	packetsOut  prom.Counter     // Writes handed to connections
+	bytesIn     prom.Counter     // Bytes of the frames read
+	bytesOut    prom.Counter     // Bytes of the writes handed to connections
+	handler     prom.Histogram   // Time spent in packet handlers
+	errors      *prom.CounterVec // Errors, labelled by kind
+}
+
+// New creates `Metrics` and registers its collectors with `registerer`
+//
+// Collectors already registered by an earlier call are reused, so servers cloned with `CloneConfig` share them. To
+// tell servers sharing a registry apart, wrap it with `prometheus.WrapRegistererWith` and a server label.
+func New(registerer prom.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		accepted: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "accepted_connections_total",
+			Help:      "Connections accepted, before admission checks.",
+		}),
+		connections: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connections",
+			Help:      "Connections currently open as sessions.",
+		}),
+		packetsIn: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_received_total",
+			Help:      "Frames read from sessions.",
+		}),
+		packetsOut: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_sent_total",
+			Help:      "Writes handed to session connections.",
+		}),
+		bytesIn: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "received_bytes_total",
+			Help:      "Bytes of the frames read from sessions.",
+		}),
+		bytesOut: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "sent_bytes_total",
+			Help:      "Bytes of the writes handed to session connections.",
+		}),
+		handler: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent handling a packet.",
+			Buckets:   prom.ExponentialBuckets(0.0001, 4, 10), // 100µs to about 26s
+		}),
+		errors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Errors, by kind.",
+		}, []string{"kind"}),
+	}
+
+	// Register the collectors, reusing those an earlier call registered
+	var err error
+	register := func(collector prom.Collector) prom.Collector {
+		if err != nil {
+			return collector
+		}
+		if regErr := registerer.Register(collector); regErr != nil {
+			var already prom.AlreadyRegisteredError
+			if errors.As(regErr, &already) {
+				return already.ExistingCollector
+			}
+			err = fmt.Errorf("tcpserve/prometheus: registering collector: %w", regErr)
+		}
+
+		return collector
+	}
+	m.accepted = register(m.accepted).(prom.Counter)
+	m.connections = register(m.connections).(prom.Gauge)
+	m.packetsIn = register(m.packetsIn).(prom.Counter)
+	m.packetsOut = register(m.packetsOut).(prom.Counter)
+	m.bytesIn = register(m.bytesIn).(prom.Counter)
+	m.bytesOut = register(m.bytesOut).(prom.Counter)
+	m.handler = register(m.handler).(prom.Histogram)
+	m.errors = register(m.errors).(*prom.CounterVec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Export every known kind from the start, so rates work before the first error
+	for _, kind := range []string{tcpserve.ErrorAccept, tcpserve.ErrorRead, tcpserve.ErrorWrite, tcpserve.ErrorVersion} {
+		m.errors.WithLabelValues(kind)
+	}
+
+	return m, nil
+}
+
+// WithMetrics returns a `ServerOptionE` which reports the server's measurements to collectors registered with
+// `registerer`, see `New`
+func WithMetrics(registerer prom.Registerer) tcpserve.ServerOptionE {
+	return func(s *tcpserve.Server) error {
+		m, err := New(registerer)
+		if err != nil {
+			return fmt.Errorf("WithMetrics: %w", err)
+		}
+		tcpserve.WithMetrics(m)(s)
+
+		return nil
+	}
+}
+
+func (m *Metrics) Accepted() {
+	m.accepted.Inc()
+}
+
+func (m *Metrics) ConnectionOpened() {
+	m.connections.Inc()
+}
+
+func (m *Metrics) ConnectionClosed() {
+	m.connections.Dec()
+}
+
+func (m *Metrics) PacketReceived(bytes int) {
+	m.packetsIn.Inc()
+	m.bytesIn.Add(float64(bytes))
+}
+
+func (m *Metrics) PacketSent(bytes int) {
+	m.packetsOut.Inc()
+	m.bytesOut.Add(float64(bytes))
+}
+
+func (m *Metrics) HandlerDuration(d time.Duration) {
+	m.handler.Observe(d.Seconds())
+}
+
+func (m *Metrics) Error(kind string) {
+	m.errors.WithLabelValues(kind).Inc()
+}
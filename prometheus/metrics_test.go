@@ -0,0 +1,81 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matthieutran/tcpserve"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prom.NewRegistry()
+	m, err := New(registry)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	m.Accepted()
+	m.ConnectionOpened()
+	m.ConnectionOpened()
+	m.ConnectionClosed()
+	m.PacketReceived(10)
+	m.PacketReceived(5)
+	m.PacketSent(7)
+	m.HandlerDuration(time.Millisecond)
+	m.Error(tcpserve.ErrorWrite)
+
+	tests := []struct {
+		name      string
+		collector prom.Collector
+		want      float64
+	}{
+		{"accepted", m.accepted, 1},
+		{"connections", m.connections, 1},
+		{"packets received", m.packetsIn, 2},
+		{"bytes received", m.bytesIn, 15},
+		{"packets sent", m.packetsOut, 1},
+		{"bytes sent", m.bytesOut, 7},
+		{"write errors", m.errors.WithLabelValues(tcpserve.ErrorWrite), 1},
+		{"read errors", m.errors.WithLabelValues(tcpserve.ErrorRead), 0},
+	}
+	for _, test := range tests {
+		if got := testutil.ToFloat64(test.collector); got != test.want {
+			t.Errorf("%s = %v, want %v", test.name, got, test.want)
+		}
+	}
+	if got := testutil.CollectAndCount(m.handler); got != 1 {
+		t.Errorf("handler duration series = %d, want 1", got)
+	}
+}
+
+func TestNewReusesCollectors(t *testing.T) {
+	registry := prom.NewRegistry()
+	first, err := New(registry)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	second, err := New(registry) // As a server cloned with `CloneConfig` does
+	if err != nil {
+		t.Fatalf("second New: %v", err)
+	}
+
+	second.Accepted()
+	if got := testutil.ToFloat64(first.accepted); got != 1 {
+		t.Errorf("accepted through the first Metrics = %v, want 1", got)
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	registry := prom.NewRegistry()
+	registry.MustRegister(prom.NewGauge(prom.GaugeOpts{Namespace: namespace, Name: "connections", Help: "Something else."}))
+
+	_, err := tcpserve.NewServerE(
+		WithMetrics(registry),
+		tcpserve.Infallible(tcpserve.WithOnPacket(func(*tcpserve.Session, []byte) {})),
+	)
+	if err == nil {
+		t.Fatal("NewServerE succeeded with a conflicting collector")
+	}
+}
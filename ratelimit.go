@@ -0,0 +1,135 @@
+package tcpserve
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is reported to `onDisconnected` for sessions disconnected by `WithRateLimit`
+var ErrRateLimited = errors.New("tcpserve: session exceeded its packet rate")
+
+// A RateLimit caps the connections of each remote IP and the packet rate of each session
+type RateLimit struct {
+	MaxPerIP         int     // Concurrent connections allowed per remote IP; 0 means no limit
+	PacketsPerSecond float64 // Packets refilled into each session's bucket per second; 0 means no limit
+	Burst            int     // Size of each session's bucket, the packets it may send at once
+	MaxDropped       int     // Packets dropped in a row before the session is disconnected; 0 never disconnects
+
+	// Called when a packet is dropped (`session` set, `dropped` false), a session is disconnected (`dropped` true) or a
+	// connection is rejected for its IP's limit (`session` nil)
+	OnThrottle func(ip string, session *Session, dropped bool)
+}
+
+// WithRateLimit returns a `ServerOption` which the Server constructor uses to modify its `rates` member
+//
+// Connections from an IP already holding `limit.MaxPerIP` sessions are closed before any other admission work.
+// Packets arriving faster than each session's token bucket allows are dropped after decryption, so stateful ciphers
+// stay in step, and never reach the handlers.
+func WithRateLimit(limit RateLimit) ServerOption {
+	return func(s *Server) {
+		s.rates = &rateLimiter{
+			limit:       limit,
+			connections: make(map[string]int),
+		}
+	}
+}
+
+// rateLimiter enforces a `RateLimit`
+type rateLimiter struct {
+	limit       RateLimit
+	mu          sync.Mutex
+	connections map[string]int // Open connections per remote IP
+}
+
+// tokenBucket meters a single session's packets; it is only used by the session's read loop
+type tokenBucket struct {
+	tokens  float64   // Packets the session may still send
+	last    time.Time // When `tokens` was last refilled
+	dropped int       // Packets dropped in a row
+}
+
+// limitIP counts a new connection against its IP's limit, returning false if it must be rejected
+func (s *Server) limitIP(conn net.Conn) bool {
+	if s.rates == nil || s.rates.limit.MaxPerIP <= 0 {
+		return true
+	}
+
+	ip := remoteIP(conn.RemoteAddr())
+
+	s.rates.mu.Lock()
+	ok := s.rates.connections[ip] < s.rates.limit.MaxPerIP
+	if ok {
+		s.rates.connections[ip] += 1
+	}
+	s.rates.mu.Unlock()
+
+	if !ok {
+		if s.rates.limit.OnThrottle != nil {
+			s.rates.limit.OnThrottle(ip, nil, true)
+		}
+		s.logWarn("Rejecting connection, too many open from its IP", Field{"remote", conn.RemoteAddr()}, Field{"open", s.rates.limit.MaxPerIP})
+	}
+
+	return ok
+}
+
+// releaseIP gives back the slot `limitIP` took for `conn`
+func (s *Server) releaseIP(conn net.Conn) {
+	if s.rates == nil || s.rates.limit.MaxPerIP <= 0 {
+		return
+	}
+
+	ip := remoteIP(conn.RemoteAddr())
+
+	s.rates.mu.Lock()
+	defer s.rates.mu.Unlock()
+
+	if s.rates.connections[ip] -= 1; s.rates.connections[ip] <= 0 {
+		delete(s.rates.connections, ip) // Don't keep every IP ever seen
+	}
+}
+
+// newBucket returns a full token bucket for a new session, or nil if packets are not limited
+func (s *Server) newBucket() *tokenBucket {
+	if s.rates == nil || s.rates.limit.PacketsPerSecond <= 0 {
+		return nil
+	}
+
+	return &tokenBucket{tokens: float64(s.rates.limit.Burst), last: s.now()}
+}
+
+// allowPacket takes a token from the session's bucket, reporting false if the packet must be dropped and
+// `ErrRateLimited` once the session has to go
+func (s *Server) allowPacket(session *Session) (bool, error) {
+	b := session.bucket
+	if b == nil {
+		return true, nil
+	}
+
+	now := s.now()
+	b.tokens += now.Sub(b.last).Seconds() * s.rates.limit.PacketsPerSecond
+	if burst := float64(s.rates.limit.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens -= 1
+		b.dropped = 0
+		return true, nil
+	}
+
+	b.dropped += 1
+	session.Record(EventThrottle, "packet rate exceeded")
+	dropped := s.rates.limit.MaxDropped > 0 && b.dropped >= s.rates.limit.MaxDropped
+	if s.rates.limit.OnThrottle != nil {
+		s.rates.limit.OnThrottle(remoteIP(session.Conn().RemoteAddr()), session, dropped)
+	}
+	if dropped {
+		return false, ErrRateLimited
+	}
+
+	return false, nil
+}
@@ -0,0 +1,75 @@
+package tcpserve
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithLogger returns a `SessionOption` which the Session constructor uses to modify its `log` member
+//
+// `LogEvery` messages reach `logger` formatted like those of `WithLoggers`. Sessions of a server log through the
+// server's loggers instead.
+func WithLogger(logger Logger) SessionOption {
+	return func(s *Session) {
+		if logger == nil {
+			s.log = nil
+			return
+		}
+		s.log = func(level LogLevel, msg string, fields []Field) {
+			line := formatEvent(msg, fields)
+			switch level {
+			case LevelDebug:
+				line = fmt.Sprint("[Debug]", line)
+			case LevelWarn:
+				line = fmt.Sprint("[Warn]", line)
+			case LevelError:
+				line = fmt.Sprint("[Error]", line)
+			}
+			logger(line)
+		}
+	}
+}
+
+// logLimit tracks one deduplicated message for `LogEvery`
+type logLimit struct {
+	last       time.Time // When the message was last logged
+	suppressed int       // Occurrences dropped since then
+}
+
+// LogEvery logs `msg` at `level` through the session's logger at most once per `period` for each `key`
+//
+// The event carries the session's ID as its "session" field, ahead of `fields`. Occurrences within the period are
+// dropped and counted; the next event logged for `key` reports how many were suppressed in its "suppressed" field.
+// Without a logger LogEvery does nothing.
+func (s *Session) LogEvery(key string, period time.Duration, level LogLevel, msg string, fields ...Field) {
+	if s.log == nil {
+		return
+	}
+
+	now := time.Now()
+
+	s.stateMu.Lock()
+	if s.logLimits == nil {
+		s.logLimits = make(map[string]*logLimit)
+	}
+	limit, ok := s.logLimits[key]
+	if !ok {
+		limit = &logLimit{}
+		s.logLimits[key] = limit
+	}
+	if ok && now.Sub(limit.last) < period {
+		limit.suppressed += 1
+		s.stateMu.Unlock()
+		return
+	}
+	suppressed := limit.suppressed
+	limit.last = now
+	limit.suppressed = 0
+	s.stateMu.Unlock()
+
+	event := append([]Field{{"session", s.id}}, fields...)
+	if suppressed > 0 {
+		event = append(event, Field{"suppressed", suppressed})
+	}
+	s.log(level, msg, event)
+}
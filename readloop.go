@@ -0,0 +1,89 @@
+package tcpserve
+
+import (
+	"time"
+)
+
+// maxRetainedPending is the most room for partial frames a session keeps once the frames that needed it are handled
+const maxRetainedPending = 8 * readBufferSize
+
+// A messageConn is a connection carrying messages, of which a read may return only part
+//
+// `readFrames` keeps reading until the message is complete before framing it, so that with the default framer one
+// message is one packet, however large.
+type messageConn interface {
+	// midMessage reports whether the last read stopped short of the end of a message
+	midMessage() bool
+}
+
+// readFrames reads from the session's connection and calls `handle` with every complete frame, until reading fails,
+// the framer rejects the stream or `handle` returns an error, which is returned
+//
+// `wire` is the whole frame and `packet` the part the framer extracted; both are only valid during the call.
+func readFrames(session *Session, buffers BufferPool, now func() time.Time, handle func(wire, packet []byte) error) error {
+	pending := buffers.Get(readBufferSize)[:0] // Bytes read but not framed yet
+	chunk := buffers.Get(readBufferSize)       // Scratch buffer for each read
+
+	// Give the buffers back once the connection is done
+	defer func() {
+		buffers.Put(chunk)
+		buffers.Put(pending)
+	}()
+
+	// Handle each incoming read
+	for {
+		n, err := session.Read(chunk) // Attempt to read from the connection
+		if err != nil {
+			if isTimeout(err) && session.midFrame() {
+				return ErrFrameStalled // The partial frame timeout ran out, not the idle timeout
+			}
+			return err
+		}
+
+		session.touch(now())                    // Timestamp the session's latest activity
+		pending = append(pending, chunk[:n]...) // Add to whatever partial frame we already have
+		if conn, ok := session.Conn().(messageConn); ok && conn.midMessage() {
+			continue // Frame the message once all of it is read
+		}
+
+		// Handle each complete frame
+		consumed := 0
+		for {
+			framer := session.currentFramer()
+			packet, size, err := framer.Split(pending[consumed:])
+			if err != nil {
+				return err
+			}
+			if size == 0 {
+				break // Wait for the rest of the frame
+			}
+
+			wire := pending[consumed : consumed+size]
+			consumed += size
+			if len(packet) == 0 {
+				switch emptyFramePolicy(framer) {
+				case DropEmptyFrames:
+					continue
+				case KeepaliveEmptyFrames:
+					if session.onKeepalive != nil {
+						session.onKeepalive(session)
+					}
+					continue
+				}
+			}
+			if err := handle(wire, packet); err != nil {
+				return err
+			}
+		}
+		pending = pending[:copy(pending, pending[consumed:])] // Keep only the partial frame
+		if cap(pending) > maxRetainedPending && len(pending) <= readBufferSize {
+			pending = append(buffers.Get(readBufferSize)[:0], pending...) // Let go of the room a large frame needed
+		}
+
+		var stall time.Duration
+		if len(pending) > 0 {
+			stall = partialFrameTimeout(session.currentFramer()) // Each read of the frame's rest earns more time
+		}
+		session.setStallTimeout(stall)
+	}
+}
@@ -0,0 +1,172 @@
+package tcpserve
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// registry is the concurrency-safe set of a server's connected sessions
+//
+// Sessions are spread over shards by ID, each with its own lock, so connections coming and going on very large
+// servers don't all contend on a single mutex.
+type registry struct {
+	next   int64 // ID handed to the next session, accessed atomically; first for 64-bit atomic alignment
+	shards []registryShard
+}
+
+// registryShard holds the sessions whose ID falls into it
+type registryShard struct {
+	mu       sync.RWMutex
+	sessions map[int]*Session // Connected sessions, keyed by ID
+}
+
+// newRegistry creates an empty registry of `shards` shards
+func newRegistry(shards int) *registry {
+	if shards < 1 {
+		shards = 1 // `Validate` reports the mistake, keep the server usable until then
+	}
+
+	r := &registry{shards: make([]registryShard, shards)}
+	for i := range r.shards {
+		r.shards[i].sessions = make(map[int]*Session)
+	}
+
+	return r
+}
+
+// shard returns the shard holding the session with ID `id`
+func (r *registry) shard(id int) *registryShard {
+	return &r.shards[uint(id)%uint(len(r.shards))] // IDs are sequential, so this spreads them evenly
+}
+
+// nextID reserves a fresh session ID
+func (r *registry) nextID() int {
+	return int(atomic.AddInt64(&r.next, 1) - 1)
+}
+
+// add registers `session` under its ID
+func (r *registry) add(session *Session) {
+	shard := r.shard(session.id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.sessions[session.id] = session
+}
+
+// remove forgets the session with ID `id`
+func (r *registry) remove(id int) {
+	shard := r.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	delete(shard.sessions, id)
+}
+
+// get returns the session with ID `id`
+func (r *registry) get(id int) (*Session, bool) {
+	shard := r.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	session, ok := shard.sessions[id]
+
+	return session, ok
+}
+
+// count returns the number of registered sessions
+func (r *registry) count() int {
+	n := 0
+	for i := range r.shards {
+		n += r.shards[i].count()
+	}
+
+	return n
+}
+
+// snapshot returns every registered session, in no particular order
+func (r *registry) snapshot() []*Session {
+	sessions := make([]*Session, 0, r.count())
+	for i := range r.shards {
+		sessions = r.shards[i].appendTo(sessions)
+	}
+
+	return sessions
+}
+
+// count returns the number of sessions in the shard
+func (s *registryShard) count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.sessions)
+}
+
+// appendTo appends every session of the shard to `sessions`
+func (s *registryShard) appendTo(sessions []*Session) []*Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+// WithRegistryShards returns a `ServerOption` which the Server constructor uses to modify its `registryShards` member
+//
+// A single shard is used by default. Servers holding hundreds of thousands of connections benefit from more shards,
+// which `RangeShard` also lets broadcast workers walk in parallel.
+func WithRegistryShards(n int) ServerOption {
+	return func(s *Server) {
+		s.registryShards = n
+	}
+}
+
+// Sessions returns every connected session, in no particular order
+func (s *Server) Sessions() []*Session {
+	return s.sessions.snapshot()
+}
+
+// Session returns the connected session with ID `id`
+func (s *Server) Session(id int) (*Session, bool) {
+	return s.sessions.get(id)
+}
+
+// Count returns the number of connected sessions
+func (s *Server) Count() int {
+	return s.sessions.count()
+}
+
+// Range calls `fn` for every connected session until it returns false
+//
+// Range works on a snapshot, so `fn` may safely message, close or look up sessions. Sessions connecting during the
+// call may be missed, and sessions disconnecting during the call may still be visited.
+func (s *Server) Range(fn func(*Session) bool) {
+	for _, session := range s.sessions.snapshot() {
+		if !fn(session) {
+			return
+		}
+	}
+}
+
+// Shards returns the number of shards of the session registry, see `WithRegistryShards`
+func (s *Server) Shards() int {
+	return len(s.sessions.shards)
+}
+
+// RangeShard calls `fn` for every connected session of shard `shard` until it returns false
+//
+// Shards don't overlap, so broadcast workers can each take a shard from 0 to `Shards()`-1 and run concurrently. Like
+// `Range`, RangeShard works on a snapshot of the shard.
+func (s *Server) RangeShard(shard int, fn func(*Session) bool) {
+	if shard < 0 || shard >= len(s.sessions.shards) {
+		return
+	}
+
+	for _, session := range s.sessions.shards[shard].appendTo(nil) {
+		if !fn(session) {
+			return
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package tcpserve
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoCorrelator is returned by `Session.Request` and `Session.WriteNotify` when the session has no `Correlator`
+var ErrNoCorrelator = errors.New("tcpserve: session has no correlator")
+
+// ErrAckTimeout is passed to a `WriteNotify` callback when the peer did not acknowledge the write in time
+var ErrAckTimeout = errors.New("tcpserve: write was not acknowledged in time")
+
+// A Correlator attaches correlation IDs to outgoing requests and reads them back from incoming replies
+type Correlator struct {
+	Attach  func(id uint32, data []byte) []byte // Returns the request packet with `id` written into it
+	Extract func(data []byte) (uint32, bool)    // Returns the correlation ID of a reply packet, if it has one
+}
+
+// CorrelationField returns a `Correlator` which stores the correlation ID as a 4 byte field at `offset` of each packet
+//
+// Requests are copied before the field is written, so callers may reuse their buffers. Replies shorter than the
+// field are not treated as replies.
+func CorrelationField(offset int, order binary.ByteOrder) Correlator {
+	return Correlator{
+		Attach: func(id uint32, data []byte) []byte {
+			size := len(data)
+			if size < offset+4 {
+				size = offset + 4 // Grow packet to fit the field
+			}
+			packet := make([]byte, size) // Leave the caller's slice and its spare capacity untouched
+			copy(packet, data)
+			order.PutUint32(packet[offset:], id)
+
+			return packet
+		},
+		Extract: func(data []byte) (uint32, bool) {
+			if len(data) < offset+4 {
+				return 0, false
+			}
+
+			return order.Uint32(data[offset:]), true
+		},
+	}
+}
+
+// WithCorrelator returns a `SessionOption` which the Session constructor uses to modify its `correlator` member
+func WithCorrelator(correlator Correlator) SessionOption {
+	return func(s *Session) {
+		s.correlator = &correlator
+	}
+}
+
+// Request sends `data` with a fresh correlation ID and blocks until the matching reply arrives or `ctx` is done
+//
+// Replies are consumed by the request and never reach `onPacket`. Request must not be called from the session's
+// own `onPacket` callback, since the reply is read by the same goroutine.
+func (s *Session) Request(ctx context.Context, data []byte) ([]byte, error) {
+	if s.correlator == nil {
+		return nil, ErrNoCorrelator
+	}
+
+	id, reply := s.expectReply()
+	defer s.forgetReply(id) // Ensure the pending request is forgotten once we stop waiting
+
+	if _, err := s.Write(s.correlator.Attach(id, data)); err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// An AckCallback is invoked once with the peer's acknowledgement of a write, or with the reason none came
+type AckCallback func(ack []byte, err error)
+
+// WriteNotify sends `data` with a fresh correlation ID like `Request`, but returns once the frame has been handed to
+// the kernel instead of waiting for the reply
+//
+// The peer acknowledges the write by replying with the same correlation ID; `acked` is then called with the reply,
+// which never reaches `onPacket`. If no acknowledgement arrives within `timeout` (0 waits as long as the session
+// lives), `acked` is called with `ErrAckTimeout`, or with the session context's error if the session ends first.
+// `acked` runs on its own goroutine and is not called at all if the write itself fails. Unlike `Request`, WriteNotify
+// may be called from `onPacket`.
+func (s *Session) WriteNotify(data []byte, timeout time.Duration, acked AckCallback) (int, error) {
+	if s.correlator == nil {
+		return 0, ErrNoCorrelator
+	}
+
+	id, reply := s.expectReply()
+	n, err := s.Write(s.correlator.Attach(id, data))
+	if err != nil {
+		s.forgetReply(id)
+		return n, err
+	}
+
+	go func() {
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		var reason error
+		select {
+		case ack := <-reply:
+			acked(ack, nil)
+			return
+		case <-expired:
+			reason = ErrAckTimeout
+		case <-s.Context().Done():
+			reason = s.Context().Err()
+		}
+
+		if !s.forgetReply(id) {
+			acked(<-reply, nil) // The acknowledgement is already on its way
+			return
+		}
+		acked(nil, reason)
+	}()
+
+	return n, nil
+}
+
+// expectReply reserves the next correlation ID and returns the channel its reply will be delivered to
+func (s *Session) expectReply() (uint32, chan []byte) {
+	id := atomic.AddUint32(&s.requestIndx, 1) // Reserve the next correlation ID
+	reply := make(chan []byte, 1)             // Buffered so `resolve` never blocks on an abandoned request
+
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	if s.pending == nil {
+		s.pending = make(map[uint32]chan []byte)
+	}
+	s.pending[id] = reply
+
+	return id, reply
+}
+
+// forgetReply stops waiting for the reply to `id`, returning false if `resolve` already took it
+func (s *Session) forgetReply(id uint32) bool {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	_, ok := s.pending[id]
+	delete(s.pending, id)
+
+	return ok
+}
+
+// resolve hands `data` to the pending request it replies to, returning whether it was consumed
+func (s *Session) resolve(data []byte) bool {
+	if s.correlator == nil {
+		return false
+	}
+
+	id, ok := s.correlator.Extract(data)
+	if !ok {
+		return false
+	}
+
+	s.pendingMu.Lock()
+	reply, ok := s.pending[id]
+	delete(s.pending, id) // Only the first reply is delivered
+	s.pendingMu.Unlock()
+
+	if ok {
+		reply <- append([]byte(nil), data...) // The reply outlives the read loop's buffer
+	}
+
+	return ok
+}
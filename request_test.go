@@ -0,0 +1,69 @@
+package tcpserve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCorrelationFieldAttachCopies(t *testing.T) {
+	correlator := CorrelationField(2, binary.LittleEndian)
+
+	data := make([]byte, 3, 16) // Spare capacity an append would write into
+	data[0], data[1], data[2] = 1, 2, 3
+	backing := data[:cap(data)]
+
+	packet := correlator.Attach(7, data)
+	if want := []byte{1, 2, 7, 0, 0, 0}; !bytes.Equal(packet, want) {
+		t.Fatalf("Attach = %v, want %v", packet, want)
+	}
+	if want := append([]byte{1, 2, 3}, make([]byte, 13)...); !bytes.Equal(backing, want) {
+		t.Fatalf("Attach modified the caller's buffer: %v", backing)
+	}
+	if id, ok := correlator.Extract(packet); !ok || id != 7 {
+		t.Fatalf("Extract = %d, %v, want 7, true", id, ok)
+	}
+}
+
+func TestWriteNotifyAck(t *testing.T) {
+	correlator := CorrelationField(0, binary.LittleEndian)
+	session := NewSession(WithConn(discardConn{}), WithCorrelator(correlator))
+
+	type result struct {
+		ack []byte
+		err error
+	}
+	acked := make(chan result, 1)
+	if _, err := session.WriteNotify([]byte{0, 0, 0, 0, 'x'}, time.Minute, func(ack []byte, err error) {
+		acked <- result{ack, err}
+	}); err != nil {
+		t.Fatalf("WriteNotify() error = %v", err)
+	}
+
+	ack := correlator.Attach(1, []byte{0, 0, 0, 0, 'o', 'k'}) // First correlation ID handed out
+	if !session.resolve(ack) {
+		t.Fatal("acknowledgement was not consumed")
+	}
+	if res := <-acked; res.err != nil || !bytes.Equal(res.ack, ack) {
+		t.Errorf("acked(%v, %v), want the acknowledgement", res.ack, res.err)
+	}
+}
+
+func TestWriteNotifyTimeout(t *testing.T) {
+	session := NewSession(WithConn(discardConn{}), WithCorrelator(CorrelationField(0, binary.LittleEndian)))
+
+	acked := make(chan error, 1)
+	if _, err := session.WriteNotify([]byte("ping"), time.Millisecond, func(_ []byte, err error) {
+		acked <- err
+	}); err != nil {
+		t.Fatalf("WriteNotify() error = %v", err)
+	}
+	if err := <-acked; !errors.Is(err, ErrAckTimeout) {
+		t.Errorf("acked error = %v, want %v", err, ErrAckTimeout)
+	}
+	if session.resolve(CorrelationField(0, binary.LittleEndian).Attach(1, nil)) {
+		t.Error("late acknowledgement was consumed after the timeout")
+	}
+}
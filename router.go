@@ -0,0 +1,138 @@
+package tcpserve
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// An Opcode identifies the kind of a packet
+type Opcode uint16
+
+// A HandlerFunc handles the packets of a single opcode; `payload` is the packet without its opcode
+type HandlerFunc func(s *Session, payload []byte)
+
+// A Router dispatches decoded packets to handlers registered by opcode
+type Router struct {
+	mu         sync.RWMutex
+	handlers   map[Opcode]HandlerFunc // Handlers keyed by the opcode they handle
+	opcodeSize int                    // Size of the opcode at the start of each packet: 1 or 2 bytes
+	order      binary.ByteOrder       // Byte order of 2 byte opcodes
+	usage      *usageStats            // Per-opcode traffic, if enabled
+}
+
+// A RouterOption configures a `Router`
+type RouterOption func(*Router)
+
+// NewRouter creates a `Router`, by default reading a 2 byte little-endian opcode at the start of each packet
+func NewRouter(options ...RouterOption) *Router {
+	r := &Router{
+		handlers:   make(map[Opcode]HandlerFunc),
+		opcodeSize: 2,
+		order:      binary.LittleEndian,
+	}
+
+	// Call each option
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// WithOpcodeSize returns a `RouterOption` which the Router constructor uses to modify its `opcodeSize` member
+//
+// `size` must be 1 or 2.
+func WithOpcodeSize(size int) RouterOption {
+	return func(r *Router) {
+		r.opcodeSize = size
+	}
+}
+
+// WithOpcodeOrder returns a `RouterOption` which the Router constructor uses to modify its `order` member
+func WithOpcodeOrder(order binary.ByteOrder) RouterOption {
+	return func(r *Router) {
+		r.order = order
+	}
+}
+
+// WithRouter returns a `ServerOption` which the Server constructor uses to modify its `router` member
+//
+// Packets are dispatched by opcode to the router's handlers. Packets without a registered handler go to `onPacket`
+// if it is set, and are dropped otherwise.
+func WithRouter(router *Router) ServerOption {
+	return func(s *Server) {
+		s.router = router
+	}
+}
+
+// Handle registers `handler` for the packets of `opcode`, replacing any previous handler
+func (r *Router) Handle(opcode Opcode, handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[opcode] = handler
+}
+
+// Opcode reads the opcode at the start of `packet`, returning false if the packet is too short to have one
+func (r *Router) Opcode(packet []byte) (Opcode, bool) {
+	if len(packet) < r.opcodeSize {
+		return 0, false
+	}
+	if r.opcodeSize == 1 {
+		return Opcode(packet[0]), true
+	}
+
+	return Opcode(r.order.Uint16(packet)), true
+}
+
+// handler returns the handler registered for `opcode`
+func (r *Router) handler(opcode Opcode) (HandlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[opcode]
+
+	return handler, ok
+}
+
+// Dispatch calls the handler registered for the opcode of `packet`, returning false if there is none
+func (r *Router) Dispatch(s *Session, packet []byte) bool {
+	opcode, ok := r.Opcode(packet)
+	if !ok {
+		return false
+	}
+
+	handler, ok := r.handler(opcode)
+	if r.usage != nil {
+		if !ok {
+			r.usage.record(opcode, len(packet), false, false, 0)
+			return false
+		}
+		r.dispatchCounted(opcode, handler, s, packet)
+		return true
+	}
+	if !ok {
+		return false
+	}
+	handler(s, packet[r.opcodeSize:])
+
+	return true
+}
+
+// route hands `packet` to the router, falling back to `onPacket` for packets it has no handler for
+func (s *Server) route(session *Session, packet []byte) {
+	if s.router.Dispatch(session, packet) {
+		return
+	}
+
+	if s.onPacket != nil {
+		s.onPacket(session, packet)
+		return
+	}
+
+	if opcode, ok := s.router.Opcode(packet); ok {
+		session.LogEvery(fmt.Sprint("unhandled opcode ", opcode), unhandledLogPeriod, LevelInfo, "No handler for opcode",
+			Field{"opcode", fmt.Sprintf("0x%X", uint16(opcode))})
+	}
+}
@@ -0,0 +1,62 @@
+package tcpserve
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+// ErrResumeOffset is returned by `ResumeFile` when the requested offset lies outside the file
+var ErrResumeOffset = errors.New("tcpserve: resume offset is beyond the end of the file")
+
+// ErrPrefixMismatch is returned by `ResumeFile` when the client's hash of the received prefix does not match the file
+var ErrPrefixMismatch = errors.New("tcpserve: received prefix does not match the file")
+
+// SendFile streams the remainder of `f` to the session, bypassing the encrypter
+//
+// The file is copied straight onto the connection, so plain TCP sessions use sendfile(2) where the platform supports
+// it and never bring the payload into user space. Any framing the peer expects must already be part of the file.
+func (s *Session) SendFile(f *os.File) (int64, error) {
+	s.tlsMu.RLock()
+	defer s.tlsMu.RUnlock()
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+
+	n, err := io.Copy(s.conn, f) // `net.TCPConn.ReadFrom` picks sendfile for *os.File sources
+	s.writes.add(int(n), err)
+
+	return n, err
+}
+
+// ResumeFile continues an interrupted `SendFile` of `f` from byte `offset`, which the client asked for after reconnecting
+//
+// When `h` is set, the first `offset` bytes of the file are hashed with it and compared to `sum`, the client's hash of
+// what it already received; on a mismatch nothing is sent and `ErrPrefixMismatch` is returned so the client can start
+// over. The rest of the file is sent like `SendFile`, and the returned count excludes the skipped prefix.
+func (s *Session) ResumeFile(f *os.File, offset int64, sum []byte, h hash.Hash) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 || offset > info.Size() {
+		return 0, ErrResumeOffset
+	}
+
+	if h != nil {
+		h.Reset()
+		if _, err := io.Copy(h, io.NewSectionReader(f, 0, offset)); err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(h.Sum(nil), sum) {
+			return 0, ErrPrefixMismatch
+		}
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return s.SendFile(f)
+}
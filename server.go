@@ -1,51 +1,157 @@
 package tcpserve
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"runtime/trace"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // A Logger is classified as a function that can take in a string
 type Logger func(string)
 
 type Server struct {
-	sessions    map[int]*Session       // A map of current sessions
-	isAlive     bool                   // Server online
-	port        int                    // Port number that server will run on
-	sessionIndx int                    // Keeps track of what index sessions is on
-	onPacket    func(*Session, []byte) // Callback function when a new packet is received
-	onConnected func(*Session)         // Callback function when a new connection is made
-	errLog      Logger
-	log         Logger
-	ln          net.Listener
-	wg          *sync.WaitGroup
+	sessions        *registry                                       // Current sessions
+	registryShards  int                                             // Number of shards `sessions` is split into
+	changes         *changeStream                                   // Deltas of `sessions` for `Changes`
+	events          *eventStream                                    // Stream of `Events`
+	done            chan struct{}                                   // Closed once the server stops accepting
+	stopOnce        sync.Once                                       // Ensures `done` is closed once
+	finishOnce      sync.Once                                       // Ensures resources are released once, however many times the server is stopped
+	port            int                                             // Port number that server will run on
+	options         []ServerOption                                  // Options the server was created with
+	tags            map[string]string                               // Tags every session starts with
+	onPacket        func(*Session, []byte)                          // Callback function when a new packet is received
+	onConnected     func(*Session)                                  // Callback function when a new connection is made
+	onDisconnected  func(*Session, error)                           // Callback function when a connection is closed
+	onRawPacket     func(*Session, []byte, []byte)                  // Callback function receiving both the wire bytes and the decoded packet
+	onShadowPacket  func(*Session, []byte)                          // Callback function mirroring packets on shadow sessions
+	sessionOptions  []SessionOption                                 // Options applied to every new session, such as its codecs
+	connContext     func(context.Context, net.Conn) context.Context // Derives each session's context at accept time
+	correlator      *Correlator                                     // Correlator handed to each new session
+	inherited       string                                          // Name of an inherited listening socket to serve on instead of `port`
+	addrs           []string                                        // Addresses to listen on instead of `port`, if set
+	injected        []net.Listener                                  // Listeners created by the caller, served instead of binding any address
+	tlsConfig       *tls.Config                                     // TLS configuration of the listener, if serving TLS
+	webSocket       bool                                            // Whether clients connect over WebSocket
+	webSocketPath   string                                          // Path WebSocket clients must upgrade on, if any
+	transport       Transport                                       // Creates the listener instead of a TCP socket, if set
+	udp             bool                                            // Whether the server listens on UDP instead of TCP
+	udpIdle         time.Duration                                   // Time after which silent UDP sessions are closed
+	unixPath        string                                          // Unix domain socket to listen on instead of `port`, if set
+	unixPerms       os.FileMode                                     // Permissions of the socket file at `unixPath`
+	bindRetries     int                                             // Extra attempts at binding the listener before giving up
+	bindDelay       time.Duration                                   // Time to wait between bind attempts
+	clockResolution time.Duration                                   // Refresh interval of the coarse clock, if enabled
+	clock           atomic.Value                                    // Cached `*coarseClock` used for packet timestamps, once `Start` runs it
+	sessionStats    bool                                            // Whether sessions record their `SessionStats`
+	middleware      []PacketMiddleware                              // Wraps packet dispatch, outermost first
+	groupMiddleware []groupMiddleware                               // Wraps packet dispatch for sessions in a group
+	handler         HandlerFunc                                     // Packet dispatch wrapped in `middleware`, built by `Start`
+	workers         int                                             // Size of the worker pool; 0 handles packets on the read goroutine
+	buffers         BufferPool                                      // Supplies read and packet buffers
+	pool            *workerPool                                     // Runs packet handlers when `workers` is set
+	codecBarrier    func(*Session, []byte) bool                     // Packets after which reads wait for the workers, if set
+	router          *Router                                         // Dispatches packets by opcode, if set
+	versionGate     *VersionGate                                    // Client version check applied to new sessions
+	latency         *simulatedLatency                               // Artificial latency added to connections, if enabled
+	sockopts        *SocketOptions                                  // Tuning applied to accepted sockets
+	approval        *ApprovalWebhook                                // External approval of new connections, if enabled
+	addrFilter      func(net.Addr) bool                             // Decides which remote addresses may connect, if set
+	storms          *stormTracker                                   // Reconnect storm protection, if enabled
+	resolveKey      func(string) (*Session, bool)                   // Maps user keys to connected sessions
+	storeOffline    func(string, []byte)                            // Keeps messages for disconnected users
+	shutdownPacket  []byte                                          // Sent to every session by `Shutdown`
+	shutdownScore   func(*Session) int                              // Orders sessions for `Shutdown`, lowest first, if set
+	shutdownSpacing time.Duration                                   // Time `Shutdown` waits between two sessions
+	flags           FeatureFlags                                    // Decides which flagged features sessions get
+	flaggedFramers  []flaggedFramer                                 // Framers enabled by feature flags, in priority order
+	plugins         []Plugin                                        // Registered plugins, in registration order
+	shutdownHooks   map[ShutdownStage][]ShutdownHook                // Cleanup run during shutdown, by stage
+	hooksMu         sync.Mutex                                      // Guards `shutdownHooks` and `stagesRun`
+	stagesRun       map[ShutdownStage]bool                          // Stages whose hooks already ran, so repeated stops run them once
+	writes          *writeCounters                                  // Write path counters shared by all sessions
+	peaks           *peakTracker                                    // High-water marks of load
+	metrics         Metrics                                         // Receives operational measurements
+	idleTimeout     time.Duration                                   // Disconnects sessions that stay silent this long, if set
+	heartbeat       *heartbeat                                      // Keepalive pings sent to sessions, if enabled
+	cryptoCheck     *CryptoCheck                                    // Encryption self-check run on sessions, if enabled
+	onKeepalive     func(*Session)                                  // Called for empty frames of framers treating them as keepalives
+	wireTrace       *wireTracer                                     // Dumps every session's frames, if enabled
+	limit           *connLimit                                      // Cap on concurrent connections, if set
+	fullPacket      []byte                                          // Sent to connections rejected by `limit`
+	broadcasts      *broadcastCache                                 // Wire bytes of repeated broadcasts
+	fanOutWorkers   int                                             // Goroutines writing a broadcast at once
+	fanOutChunk     int                                             // Recipients each broadcast worker takes at a time
+	rates           *rateLimiter                                    // Per-IP connection and per-session packet limits, if enabled
+	timelineSize    int                                             // Events kept on each session's timeline; 0 keeps none
+	errLog          Logger
+	log             Logger
+	structured      StructuredLogger // Receives log events with their fields instead of `log` and `errLog`, if set
+	logLevel        LogLevel         // Least severe level that is logged
+	listeners       []net.Listener
+	lnMu            sync.Mutex // Guards `listeners`, which are set by `Start` and closed by `Stop`
+	wg              *sync.WaitGroup
 }
 
 type ServerOption func(*Server)
 
+const (
+	readBufferSize     = 2048             // Size of each read from a connection
+	unhandledLogPeriod = 10 * time.Second // How often each session may log the same unhandled opcode
+)
+
 func NewServer(options ...ServerOption) *Server {
 	// Default options
 	const (
 		defaultPort = 8484
 	)
 
+	discard := func(string) {} // Loggers stay silent unless `WithLoggers` is used
+
 	// Create Server object
 	s := &Server{
-		port:     defaultPort,
-		isAlive:  false,
-		sessions: make(map[int]*Session),
+		port:           defaultPort,
+		registryShards: 1,
+		done:           make(chan struct{}),
+		log:            discard,
+		errLog:         discard,
+		logLevel:       LevelInfo,
+		peaks:          &peakTracker{},
+		writes:         &writeCounters{since: time.Now()},
+		buffers:        defaultBuffers,
+		metrics:        nopMetrics{},
+		broadcasts:     newBroadcastCache(defaultBroadcastEntries),
+		fanOutWorkers:  defaultFanOutWorkers(),
+		fanOutChunk:    defaultFanOutChunk,
+		changes:        &changeStream{},
+		events:         &eventStream{},
+		wg:             &sync.WaitGroup{},
 	}
 
 	// Call each option
 	for _, option := range options {
 		option(s)
 	}
+	s.options = append([]ServerOption(nil), options...) // Kept for `CloneConfig`
+
+	s.sessions = newRegistry(s.registryShards) // Shard count is only known once the options ran
+	s.peaks.reset(s.now())                     // Start tracking from an idle server
+	s.writes.metrics = s.metrics               // Report every session's writes
 
 	return s
 }
 
 // WithPort return a `ServerOption` which the Server constructor uses to modify its `port` member
+//
+// Port 0 binds a random free port, which `Addr` reports once the server is listening.
 func WithPort(port int) ServerOption {
 	return func(s *Server) {
 		s.port = port
@@ -54,10 +160,14 @@ func WithPort(port int) ServerOption {
 
 // WithLoggers returns a `ServerOption` which the Server constructor uses to modify its `logger` members
 //
-// If the `errLogger` parameter is left empty, then the errLogger function would use the `logger` parameter with [Error] prefixed.
+// Errors go to `errLogger`; if it is left empty, then the errLogger function would use the `logger` parameter with
+// [Error] prefixed. Without WithLoggers, the server logs nothing.
+// Each event is passed as its message followed by its fields as key=value pairs; see `WithStructuredLogger` and
+// `WithSlog` to keep the fields structured.
 func WithLoggers(logger Logger, errLogger Logger) ServerOption {
 	return func(s *Server) {
 		s.log = logger
+		s.errLog = errLogger
 
 		if errLogger == nil {
 			s.errLog = func(msg string) {
@@ -68,12 +178,25 @@ func WithLoggers(logger Logger, errLogger Logger) ServerOption {
 }
 
 // WithOnPacket returns a `ServerOption` which the Server constructor uses to modify its `onPacket` member
+//
+// The packet is only valid until `onPacket` returns; copy it to keep it longer (see `WithBufferPool`).
 func WithOnPacket(onPacket func(*Session, []byte)) ServerOption {
 	return func(s *Server) {
 		s.onPacket = onPacket
 	}
 }
 
+// WithOnRawPacket returns a `ServerOption` which the Server constructor uses to modify its `onRawPacket` member
+//
+// When set, `onRawPacket` is called instead of `onPacket` with the packet exactly as it was read off the wire
+// (header included) alongside its decoded form. The raw slice is a copy taken before decryption, so it stays
+// untouched even when the decrypter works in place.
+func WithOnRawPacket(onRawPacket func(s *Session, raw []byte, decoded []byte)) ServerOption {
+	return func(s *Server) {
+		s.onRawPacket = onRawPacket
+	}
+}
+
 // WithOnConnected returns a `ServerOption` which the Server constructor uses to modify its `onConnected` member
 func WithOnConnected(onConnected func(*Session)) ServerOption {
 	return func(s *Server) {
@@ -81,109 +204,484 @@ func WithOnConnected(onConnected func(*Session)) ServerOption {
 	}
 }
 
+// WithSessionOptions returns a `ServerOption` which the Server constructor uses to modify its `sessionOptions` member
+//
+// `options` are applied to every session the server creates, before the server sets the session's ID and connection,
+// so codecs shared by all connections can be configured once: `WithSessionOptions(WithEncrypter(enc), WithDecrypter(dec))`.
+func WithSessionOptions(options ...SessionOption) ServerOption {
+	return func(s *Server) {
+		s.sessionOptions = append(s.sessionOptions, options...)
+	}
+}
+
+// WithConnContext returns a `ServerOption` which the Server constructor uses to modify its `connContext` member
+//
+// `connContext` is called for every accepted connection before `onConnected`, and the context it returns becomes the
+// session's context, so values attached at accept time (listener tag, region, TLS identity) reach every handler
+// through `Session.Context`.
+func WithConnContext(connContext func(ctx context.Context, conn net.Conn) context.Context) ServerOption {
+	return func(s *Server) {
+		s.connContext = connContext
+	}
+}
+
+// WithRequestCorrelator returns a `ServerOption` which the Server constructor uses to modify its `correlator` member
+//
+// Every session created by the server will use `correlator` for `Session.Request`.
+func WithRequestCorrelator(correlator Correlator) ServerOption {
+	return func(s *Server) {
+		s.correlator = &correlator
+	}
+}
+
+// WithBindRetry returns a `ServerOption` which the Server constructor uses to modify its `bindRetries` and `bindDelay` members
+//
+// `Start` tries to bind the listener up to `attempts` times, waiting `delay` between attempts, which helps during
+// fast restarts while the previous process's socket is still held by the kernel.
+func WithBindRetry(attempts int, delay time.Duration) ServerOption {
+	return func(s *Server) {
+		s.bindRetries = attempts - 1
+		s.bindDelay = delay
+	}
+}
+
+// WithOnDisconnected returns a `ServerOption` which the Server constructor uses to modify its `onDisconnected` member
+//
+// `onDisconnected` is called once the session's connection has been closed, with the error that ended it (`io.EOF`
+// when the client hung up cleanly).
+func WithOnDisconnected(onDisconnected func(*Session, error)) ServerOption {
+	return func(s *Server) {
+		s.onDisconnected = onDisconnected
+	}
+}
+
 // Port gets the server's listening port
-func (s Server) Port() int {
+//
+// Once the server listens on TCP, this is the bound port, so a configured port 0 reports the one picked by the system.
+func (s *Server) Port() int {
+	if addr, ok := s.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+
 	return s.port
 }
 
+// Addr returns the address of the server's first listener, or nil until `Start` is listening
+func (s *Server) Addr() net.Addr {
+	addrs := s.Addrs()
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	return addrs[0]
+}
+
+// Addrs returns the addresses of all of the server's listeners, in the order of `WithAddr`, or nil until `Start` is
+// listening
+func (s *Server) Addrs() []net.Addr {
+	s.lnMu.Lock()
+	defer s.lnMu.Unlock()
+
+	var addrs []net.Addr
+	for _, ln := range s.listeners {
+		addrs = append(addrs, ln.Addr())
+	}
+
+	return addrs
+}
+
 // Start serves the TCP server and listens for connections
 // A waitgroup needs have 1 for the TCP server and passed.
 func (s *Server) Start(wg *sync.WaitGroup) (err error) {
 	// Ensure caller's wait group is decremented when listener is closed
 	defer wg.Done()
 
-	s.wg.Add(1) // Increment wait group for the listener
-	s.ln, err = net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err = s.Validate(); err != nil {
+		return // Refuse to start with a broken configuration
+	}
+	s.handler = s.buildHandler() // Compose the middleware once options are final
+
+	s.lnMu.Lock()
+	if s.stopping() {
+		s.lnMu.Unlock()
+		return ErrServerClosed // Stopped before we started
+	}
+	s.wg.Add(1) // Increment wait group for the listeners, under `lnMu` so that `Stop` waits for it
+	s.lnMu.Unlock()
+
+	lns, err := s.listen()
 	if err != nil {
-		s.wg.Done() // Decrement wait group for the listener
+		s.wg.Done() // Decrement wait group for the listeners
 		return      // Return with error
 	}
-	// Listener server is alive
-	s.isAlive = true
-	s.log(fmt.Sprintf("TCP Server started on port %d", s.port))
 
-	// Ensure listener is closed at end of function
+	s.lnMu.Lock()
+	s.listeners = lns
+	s.lnMu.Unlock()
+	if s.stopping() {
+		closeListeners(lns) // Stopped while we were binding
+		s.wg.Done()         // Decrement wait group for the listeners
+		return
+	}
+	if s.clockResolution > 0 {
+		s.clock.Store(startCoarseClock(s.clockResolution)) // Atomic, `now` may already be reading it
+	}
+	if s.workers > 0 {
+		s.pool = startWorkerPool(s.workers, func(session *Session, j job) {
+			s.peaks.dequeue() // The packet leaves the queue for a worker
+			s.deliver(session, j)
+		})
+	}
+	s.limit.start()
+
+	// Ensure listeners are closed at end of function
 	defer func() {
-		s.ln.Close() // Close listener server
-		s.wg.Done()  // Decrement wait group for listener
+		closeListeners(lns) // Close listener servers
+		s.wg.Done()         // Decrement wait group for the listeners
 	}()
 
-	// Handle each new connection
-	for s.isAlive {
-		s.wg.Add(1)                // Increment waitgroup for this connection
-		conn, err := s.ln.Accept() // Block until new connection and accept it
+	// Accept on every listener until the server stops
+	var loops sync.WaitGroup
+	for _, ln := range lns {
+		s.logInfo("TCP Server started", Field{"addr", ln.Addr()})
+
+		loops.Add(1)
+		go func(ln net.Listener) {
+			defer loops.Done()
+			s.acceptLoop(ln)
+		}(ln)
+	}
+	loops.Wait()
+
+	return
+}
+
+// acceptLoop hands every connection `ln` accepts to its own goroutine, until the server stops
+func (s *Server) acceptLoop(ln net.Listener) {
+	for {
+		s.wg.Add(1)              // Increment waitgroup for this connection
+		conn, err := ln.Accept() // Block until new connection and accept it
 		if err != nil {
-			s.errLog(fmt.Sprint("error accepting client connection:", err))
-			conn.Close() // Close connection
-			s.wg.Done()  // Decrement wait group for connection
-			continue     // Proceed to block until next client connection
+			s.wg.Done() // Decrement wait group for connection
+			if s.stopping() {
+				return // Listener was closed by Stop or Shutdown
+			}
+
+			s.metrics.Error(ErrorAccept)
+			s.logError("error accepting client connection", Field{"addr", ln.Addr()}, Field{"error", err})
+			s.emitEvent(Event{Kind: ErrorEvent, Err: err})
+			continue // Proceed to block until next client connection
 		}
 
+		s.metrics.Accepted()
+		if !s.limit.claim(s.done) {
+			go s.turnAway(conn) // Too many connections already
+			continue
+		}
 		go s.handleConn(conn)
 	}
-
-	return
 }
 
 // handleConn listens for new packets
 func (s *Server) handleConn(conn net.Conn) {
+	s.tuneConn(conn) // Apply socket options before any traffic
+
+	tags, ok := s.admit(conn)
+	if !ok {
+		conn.Close()      // Turn the connection away before doing any work for it
+		s.limit.release() // Free the connection's slot
+		s.wg.Done()       // Decrement wait group for connection
+		return
+	}
+
+	if s.latency != nil {
+		conn = s.latency.wrap(conn) // Simulate a real network in development
+	}
+
 	// Add connection to the slice
-	id := s.sessionIndx                               // Set the current connection's ID
-	session := NewSession(WithId(id), WithConn(conn)) // Create session
-	s.sessions[id] = session                          // Add connection to the sessions map with key = id
-	s.sessionIndx += 1                                // Increment connection count for next ID
-	s.onConnected(session)                            // Send onConnected to the outside
-	s.log(fmt.Sprintf("New client connection made (ID: %d)", id))
+	id := s.sessions.nextID() // Reserve the current connection's ID
+
+	ctx := context.Background()
+	if s.connContext != nil {
+		ctx = s.connContext(ctx, conn) // Attach the caller's accept-time values
+	}
+	ctx, cancel := context.WithCancel(ctx)       // Session context ends with the connection
+	ctx, endTrace := traceSession(ctx, id, conn) // Attribute this goroutine's work to the session
+
+	options := append([]SessionOption{}, s.sessionOptions...) // Server-wide defaults such as codecs come first
+	options = append(options, WithId(id), WithConn(conn), WithContext(ctx), WithTags(s.sessionTags(tags)))
+	if s.correlator != nil {
+		options = append(options, WithCorrelator(*s.correlator))
+	}
+	session := NewSession(options...)   // Create session
+	session.writes = s.writes           // Count the session's writes with the server's
+	session.log = s.logEvent            // Log the session's events through the server's loggers
+	session.changes = s.changes         // Publish the session's tag changes
+	session.flags = s.flags             // Let the session consult the server's feature flags
+	session.idleTimeout = s.idleTimeout // Let the session disconnect silent clients
+	session.clock = s.now               // Push the session's deadlines back with the server's clock
+	session.bucket = s.newBucket()      // Meter the session's packets
+	session.onKeepalive = s.onKeepalive // Report the session's keepalive frames
+	session.tracer = s.wireTrace        // Dump the session's frames
+	if s.cryptoCheck != nil {
+		session.probe = &cryptoProbe{} // Track the session's outstanding check frame
+	}
+	if s.timelineSize > 0 {
+		session.timeline = newTimeline(s.timelineSize) // Keep the session's story for postmortems
+		session.Record(EventConnect, conn.RemoteAddr().String())
+	}
+	if s.sessionStats {
+		session.stats = newStatsRecorder() // Record the client's behavior
+	}
+	if s.pool != nil {
+		session.mailbox = &mailbox{jobs: make(chan job, sessionQueueSize)}
+	}
+	s.applyFlags(session)   // Pick flagged subsystems for the session
+	s.sessions.add(session) // Add connection to the registry
+	if s.stopping() {
+		session.stopReading() // Admitted while the server stopped, too late for it to wind the session down
+	}
+	s.peaks.connected(s.now()) // Update the concurrent connections peak
+	s.metrics.ConnectionOpened()
+	s.changes.publish(RegistryDelta{Kind: DeltaConnect, Session: id, Tags: session.Tags()})
+	session.touch(s.now())  // Count the connection as activity
+	s.startPlugins(session) // Let plugins set the session up first
+	if s.onConnected != nil {
+		s.onConnected(session) // Send onConnected to the outside
+	}
+	s.emitEvent(Event{Kind: ConnectedEvent, Session: session})
+	s.logInfo("New client connection made", Field{"session", id}, Field{"remote", conn.RemoteAddr()})
+
+	var shadow *shadow
+	if s.onShadowPacket != nil {
+		shadow = s.startShadow(session) // Mirror this session's packets to the shadow handler
+	}
+	if s.heartbeat != nil {
+		s.startHeartbeat(session) // Ping the session until its context ends
+	}
+	if s.cryptoCheck != nil {
+		s.startCryptoCheck(session) // Check the session's keys until its context ends
+	}
+
+	var reason error // Why the session ended, reported to `onDisconnected`
 
 	// Ensure connection is gracefully shut down
 	defer func() {
-		conn.Close()           // Close connection
-		delete(s.sessions, id) // Remove connection from connections map
-		s.wg.Done()            // Decrement wait group for listener
+		if shadow != nil {
+			shadow.stop() // Let the shadow finish replaying
+		}
+		if session.mailbox != nil {
+			session.mailbox.pending.Wait() // Let the workers finish the session's packets
+		}
+		cancel()               // Signal the session's end to its context's users
+		endTrace()             // End the session's trace task
+		session.Conn().Close() // Close connection
+		s.sessions.remove(id)  // Remove connection from the registry
+		s.changes.publish(RegistryDelta{Kind: DeltaDisconnect, Session: id})
+		if reason != nil {
+			session.Record(EventDisconnect, reason.Error())
+		}
+		if s.onDisconnected != nil {
+			s.onDisconnected(session, reason) // Send onDisconnected to the outside
+		}
+		s.emitEvent(Event{Kind: DisconnectedEvent, Session: session, Err: reason})
+		s.endPlugins(session)  // Let plugins clean up after the session
+		s.peaks.disconnected() // Update current connection count
+		s.metrics.ConnectionClosed()
+		s.releaseIP(conn) // Free the slot of the connection's IP
+		s.limit.release() // Free the connection's slot
+		s.wg.Done()       // Decrement wait group for listener
 	}()
 
-	// Handle each incoming packet
-	for {
-		// Read the packet without knowing its size
-		buf := make([]byte, 2048) // We set the buffer to 2048 and shrink it later
-		n, err := conn.Read(buf)  // Attempt to read from the connection
-		if err != nil {
-			// If cannot read the packet, end the loop and close connection
-			s.errLog(fmt.Sprintf("Closing connection (ID: %d). Could not read packet: %s", id, err))
-			break
+	reason = readFrames(session, s.buffers, s.now, func(wire, packet []byte) error {
+		return s.handlePacket(ctx, session, shadow, wire, packet)
+	})
+
+	switch {
+	case session.closedLocally():
+		reason = ErrSessionClosed // Closed on purpose, not a read failure
+	case s.stopping():
+		reason = ErrServerClosed // Ended by Stop or Shutdown
+	case reason == ErrRateLimited:
+		s.logInfo("Closing rate limited connection", Field{"session", id})
+	case session.heartbeatTimedOut():
+		reason = ErrHeartbeatTimeout // Peer stopped answering pings
+	case session.cryptoDesynced():
+		reason = ErrCryptoDesync // Keys of both ends drifted apart
+		s.logError("Closing connection with desynced encryption", Field{"session", id})
+	case reason == ErrFrameStalled:
+		s.logWarn("Closing connection stalled mid-frame", Field{"session", id})
+	case s.idleTimeout > 0 && isTimeout(reason):
+		reason = ErrIdleTimeout // Client went silent
+		s.logInfo("Closing idle connection", Field{"session", id})
+	case reason == ErrVersionRejected:
+		s.metrics.Error(ErrorVersion)
+	default:
+		s.metrics.Error(ErrorRead)
+		s.logError("Closing connection", Field{"session", id}, Field{"remote", conn.RemoteAddr()}, Field{"error", reason})
+		if !errors.Is(reason, io.EOF) {
+			s.emitEvent(Event{Kind: ErrorEvent, Session: session, Err: reason}) // A clean hangup is no error
+		}
+	}
+}
+
+// handlePacket decodes and dispatches one frame, returning an error if the session must be closed
+//
+// `wire` is the whole frame and `packet` the part of it the framer extracted; both are only valid during the call.
+func (s *Server) handlePacket(ctx context.Context, session *Session, shadow *shadow, wire []byte, packet []byte) error {
+	s.peaks.packet(s.now()) // Update the packet rate peak
+	s.metrics.PacketReceived(len(wire))
+
+	var raw []byte
+	if s.onRawPacket != nil {
+		raw = append([]byte(nil), wire...) // Keep the wire bytes before the decrypter touches them
+	}
+
+	session.trace(traceIn, traceWire, wire)
+	data := s.buffers.Get(len(packet)) // Decode into a buffer of our own, the framer's is reused
+	copy(data, packet)
+	res := session.Decrypt(data) // Decrypt data if there is a decrypter
+	session.trace(traceIn, tracePlain, res)
+	s.recordStats(session, res) // Count the packet in the session's behavior
+	if s.debugging() {
+		s.logPacket(session, len(wire), res)
+	}
+
+	if ok, err := s.allowPacket(session); !ok {
+		s.buffers.Put(data) // Session is sending too fast
+		return err
+	}
+
+	if echo, err := s.checkEcho(session, res); echo {
+		s.buffers.Put(data) // Check frames are not for the handlers
+		return err
+	}
+
+	if session.resolve(res) {
+		s.buffers.Put(data) // Packet was copied for a pending request
+		return nil
+	}
+
+	if !s.checkVersion(session, res) {
+		s.buffers.Put(data)
+		return ErrVersionRejected // Client version is not supported
+	}
+
+	if session.collect(res) {
+		s.buffers.Put(data) // Packet was copied for a pending ReadExpect
+		return nil
+	}
+
+	if shadow != nil {
+		shadow.mirror(res) // Copy before the real handler gets a chance to modify the packet
+	}
+
+	j := job{ctx: ctx, raw: raw, packet: res, buf: data}
+	if s.pool != nil {
+		barrier := s.codecBarrier != nil && s.codecBarrier(session, res) // Ask before a worker releases the buffer
+		s.peaks.queue(s.now())                                           // Update the queue depth peak
+		s.pool.enqueue(session, j)                                       // Let a worker handle it, in order with the session's other packets
+		if barrier {
+			session.mailbox.pending.Wait() // The handler may swap codecs, decode nothing more until it is done
 		}
+		return nil
+	}
+	s.deliver(session, j)
 
-		data := buf[4:n]             // Make a new byte slice from buffer containing the correct size packet
-		res := session.Decrypt(data) // Decrypt data if there is a decrypter
+	return nil
+}
 
-		s.onPacket(session, res) // Send event to the outside
+// deliver hands a decoded packet to the packet handlers
+func (s *Server) deliver(session *Session, j job) {
+	if s.events.enabled() {
+		s.emitEvent(Event{Kind: PacketEvent, Session: session, Packet: append([]byte(nil), j.packet...)}) // Copy, the buffer goes back to the pool
 	}
+
+	region := trace.StartRegion(j.ctx, s.handlerRegion(j.packet)) // Attribute handler latency to the session's task and opcode
+	start := time.Now()                                           // The coarse clock is too coarse for handler latency
+	if s.onRawPacket != nil {
+		s.onRawPacket(session, j.raw, j.packet) // Send both forms to the outside
+	} else {
+		s.handler(session, j.packet) // Send through the middleware to the router or onPacket
+	}
+	region.End()
+	s.metrics.HandlerDuration(time.Since(start))
+
+	s.buffers.Put(j.buf) // Handlers are done with the packet
+}
+
+// admit decides whether a freshly accepted connection may become a session, and with which tags
+func (s *Server) admit(conn net.Conn) (map[string]string, bool) {
+	if !s.filterAddr(conn) || !s.guardReconnects(conn) || !s.limitIP(conn) {
+		return nil, false
+	}
+
+	tags, ok := s.approve(conn)
+	if !ok {
+		s.releaseIP(conn) // Not counted against its IP after all
+	}
+
+	return tags, ok
 }
 
 // WriteToId sends the byte slice to the specified connection `id`
 func (s *Server) WriteToId(message []byte, id int) {
-	if session, ok := s.sessions[id]; ok {
-		session.conn.Write(message)
+	if session, ok := s.sessions.get(id); ok {
+		session.WriteRaw(message)
 	}
 }
 
 // WriteToAll sends the byte slice to all open connections
 func (s *Server) WriteToAll(message []byte) {
-	for _, session := range s.sessions {
-		session.conn.Write(message)
-	}
+	s.fanOut(s.sessions.snapshot(), func(session *Session) bool {
+		session.WriteRaw(message)
+		return true
+	})
 }
 
+// Announce sends a per-recipient message built by `builder` to every session accepted by `filter`
+//
+// A nil `filter` accepts every session, and a nil message from `builder` skips that session. Messages go through
+// each session's encrypter. Announce returns the number of sessions the message was written to. `builder` and
+// `filter` may run concurrently, see `WithBroadcastParallelism`.
+func (s *Server) Announce(builder func(*Session) []byte, filter func(*Session) bool) int {
+	return s.fanOut(s.sessions.snapshot(), func(session *Session) bool {
+		if filter != nil && !filter(session) {
+			return false
+		}
+
+		message := builder(session)
+		if message == nil {
+			return false
+		}
+
+		if _, err := session.Write(message); err != nil {
+			s.logError("Could not announce to client", Field{"session", session.id}, Field{"error", err})
+			return false
+		}
+
+		return true
+	})
+}
+
+// Stop force-closes every connection and the listener, then blocks until the server has shut down
+//
+// Use `Shutdown` to let in-flight packet handlers finish first.
 func (s *Server) Stop() (err error) {
+	ctx := context.Background()
+
+	err = s.stopAccepting() // Close listener loop
+	s.runHooks(ctx, StageStopAccepting)
+
 	// Close client connections
-	for _, connection := range s.sessions {
-		connection.conn.Close() // No error handling since we're trying to shut down anyway
-		s.wg.Done()
+	for _, session := range s.sessions.snapshot() {
+		session.Conn().Close() // No error handling since we're trying to shut down anyway
 	}
 
-	s.isAlive = false  // Close listener loop
-	err = s.ln.Close() // Close listener
-	s.wg.Wait()        // Block until server has been gracefully shut down
+	s.wg.Wait() // Block until server has been gracefully shut down
+	s.runHooks(ctx, StageForceClose)
+	s.finish()
+	s.runHooks(ctx, StageFinal)
 
 	return
 }
@@ -1,18 +1,66 @@
 package tcpserve
 
 import (
+	"context"
 	"io"
 	"net"
+	"sync"
+	"time"
 )
 
 // A Codec performs operations on an input byte slice and returns the result
 type Codec func([]byte) []byte
 
 type Session struct {
+	lastActive   int64  // Unix nanoseconds of the last received packet; first for 64-bit atomic alignment
+	capabilities uint64 // Negotiated `Capability` flags, accessed atomically
+
 	id      int
+	ctx     context.Context
 	conn    net.Conn
+	connMu  sync.RWMutex // Guards `conn` against being swapped mid-write
+	tlsMu   sync.RWMutex // Held by `UpgradeTLS` during its handshake, so writes wait for the TLS connection
+	framer  Framer
 	encrypt Codec
 	decrypt Codec
+	codecMu sync.RWMutex // Guards the codecs against being swapped mid-packet
+
+	appendEncrypt AppendCodec // Encrypter writing into pooled buffers, preferred over `encrypt` when set
+
+	correlator  *Correlator            // Attaches and extracts correlation IDs for `Request`
+	requestIndx uint32                 // Last correlation ID handed out
+	pending     map[uint32]chan []byte // Requests awaiting a reply, keyed by correlation ID
+	pendingMu   sync.Mutex
+
+	clientVersion int                             // Version reported by the client through the server's version gate
+	versionKnown  bool                            // Whether `clientVersion` has been reported
+	collector     *collector                      // Pending `ReadExpect`, if any
+	tags          map[string]string               // Labels attached to the session
+	values        map[string]interface{}          // Handler state stored with `Set`
+	log           func(LogLevel, string, []Field) // Receives the events of `LogEvery`
+	logLimits     map[string]*logLimit            // Deduplication state of `LogEvery`, keyed by message key
+	writes        *writeCounters                  // Server-wide write counters, if owned by a server
+	mailbox       *mailbox                        // Packets waiting for the server's worker pool, if it has one
+	stats         *statsRecorder                  // Behavioral statistics, if the server records them
+	flags         FeatureFlags                    // Feature flags of the server owning the session
+	closer        Closer                          // Close handshake run by `Close`
+	closeTimeout  time.Duration                   // Time after which `Close` gives up on the handshake
+	closing       int32                           // Set once `Close` has been called, accessed atomically
+	idleTimeout   time.Duration                   // Read deadline pushed back before every read, if set
+	clock         func() time.Time                // Time source of the read deadlines, the server's clock if owned by a server
+	readStopped   bool                            // Set once `Shutdown` stopped reading, so the deadline stays put
+	stallTimeout  time.Duration                   // Read deadline pushed back before every read while a frame is partial, if set
+	heartbeatLost int32                           // Set once the heartbeat gave up on the peer, accessed atomically
+	probe         *cryptoProbe                    // Outstanding encryption check frame, if the server runs the self-check
+	onKeepalive   func(*Session)                  // Called for empty keepalive frames, if the server has a callback
+	tracer        *wireTracer                     // Dumps the session's frames, if the server traces the wire
+	cryptoClass   string                          // Sessions sharing it encrypt identically, see `WithCryptoClass`
+	bucket        *tokenBucket                    // Packet rate limit of the session, if the server has one
+	timeline      *timeline                       // Recent events of the session, if the server records them
+	groups        map[string]struct{}             // Groups the session joined, see `WithGroupMiddleware`
+	changes       *changeStream                   // Registry change stream of the server owning the session
+	stateMu       sync.Mutex                      // Guards session state set after creation
+
 	io.Writer
 	io.Reader
 }
@@ -20,7 +68,7 @@ type Session struct {
 type SessionOption func(*Session)
 
 func NewSession(options ...SessionOption) *Session {
-	s := &Session{}
+	s := &Session{framer: legacyFramer{}, clock: time.Now}
 	dummy := func(b []byte) []byte {
 		return b
 	}
@@ -42,6 +90,13 @@ func WithId(id int) SessionOption {
 	}
 }
 
+// WithContext returns a `SessionOption` which the Session constructor uses to modify its `ctx` member
+func WithContext(ctx context.Context) SessionOption {
+	return func(s *Session) {
+		s.ctx = ctx
+	}
+}
+
 func WithConn(conn net.Conn) SessionOption {
 	return func(s *Session) {
 		s.conn = conn
@@ -61,10 +116,16 @@ func WithDecrypter(decrypter Codec) SessionOption {
 }
 
 func (s *Session) SetEncrypter(encrypter Codec) {
+	s.codecMu.Lock()
+	defer s.codecMu.Unlock()
+
 	s.encrypt = encrypter
 }
 
 func (s *Session) SetDecrypter(decrypter Codec) {
+	s.codecMu.Lock()
+	defer s.codecMu.Unlock()
+
 	s.decrypt = decrypter
 }
 
@@ -72,26 +133,81 @@ func (s *Session) Id() int {
 	return s.id
 }
 
+// Context returns the session's context, which is cancelled once the connection is closed by the server
+func (s *Session) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
 func (s *Session) Encrypt(data []byte) []byte {
+	s.codecMu.RLock()
+	defer s.codecMu.RUnlock()
+
 	return s.encrypt(data)
 }
 
 func (s *Session) Decrypt(data []byte) []byte {
+	s.codecMu.RLock()
+	defer s.codecMu.RUnlock()
+
 	return s.decrypt(data)
 }
 
 // Encrypt and send a slice of bytes
 func (s *Session) Write(data []byte) (int, error) {
-	res := s.Encrypt(data)
+	s.codecMu.RLock()
+	defer s.codecMu.RUnlock() // Codecs can't be swapped between encoding and sending
 
-	return s.conn.Write(res)
+	return s.write(data)
+}
+
+// write encodes and sends `data`; the caller must hold `codecMu`
+func (s *Session) write(data []byte) (int, error) {
+	s.trace(traceOut, tracePlain, data) // Before the encrypter gets a chance to work in place
+	if s.appendEncrypt != nil {
+		return s.writePooled(data)
+	}
+
+	res := s.encrypt(data)
+
+	return s.WriteRaw(s.framer.Frame(res))
+}
+
+// currentFramer returns the framer the session's stream is currently split with
+func (s *Session) currentFramer() Framer {
+	s.codecMu.RLock()
+	defer s.codecMu.RUnlock()
+
+	return s.framer
 }
 
 // Send a slice of bytes (UNENCRYPTED)
 func (s *Session) WriteRaw(data []byte) (int, error) {
-	return s.conn.Write(data)
+	s.tlsMu.RLock()
+	defer s.tlsMu.RUnlock()
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+
+	s.trace(traceOut, traceWire, data)
+	n, err := s.conn.Write(data)
+	s.writes.add(n, err)
+
+	return n, err
 }
 
 func (s *Session) Read(data []byte) (int, error) {
-	return s.conn.Read(data)
+	s.refreshDeadline() // Disconnect idle clients
+
+	return s.Conn().Read(data)
+}
+
+// Conn returns the session's current underlying connection
+func (s *Session) Conn() net.Conn {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+
+	return s.conn
 }
@@ -0,0 +1,138 @@
+package tcpserve
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// IntervalBounds are the upper bounds of the inter-packet interval histogram buckets in `SessionStats`
+//
+// Intervals longer than the last bound are counted in a final overflow bucket.
+var IntervalBounds = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// SessionStats describes the behavior of a session's client, for anti-cheat and abuse detection
+type SessionStats struct {
+	Packets        uint64            // Packets received
+	Opcodes        map[Opcode]uint64 // Packets received per opcode, when the server has a router
+	Intervals      []uint64          // Inter-packet interval histogram, bucketed by `IntervalBounds`
+	IntervalMean   time.Duration     // Mean time between packets
+	IntervalStdDev time.Duration     // Standard deviation of the time between packets; near zero means bot-like regularity
+}
+
+// WithSessionStats returns a `ServerOption` which the Server constructor uses to modify its `sessionStats` member
+//
+// Every session then records its opcode frequencies and inter-packet intervals, available through `Session.Stats`.
+func WithSessionStats() ServerOption {
+	return func(s *Server) {
+		s.sessionStats = true
+	}
+}
+
+// statsRecorder accumulates a session's `SessionStats`
+type statsRecorder struct {
+	mu        sync.Mutex
+	packets   uint64
+	opcodes   map[Opcode]uint64
+	intervals []uint64
+	last      time.Time // When the previous packet arrived
+	mean      float64   // Running mean of intervals in nanoseconds
+	m2        float64   // Running sum of squared deviations, for the variance
+	samples   uint64    // Intervals recorded
+}
+
+// newStatsRecorder creates an empty recorder
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{
+		opcodes:   make(map[Opcode]uint64),
+		intervals: make([]uint64, len(IntervalBounds)+1),
+	}
+}
+
+// record adds a packet received at `now`, and its opcode if `hasOpcode`
+func (r *statsRecorder) record(now time.Time, opcode Opcode, hasOpcode bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.packets += 1
+	if hasOpcode {
+		r.opcodes[opcode] += 1
+	}
+
+	if !r.last.IsZero() {
+		interval := now.Sub(r.last)
+
+		bucket := len(IntervalBounds)
+		for i, bound := range IntervalBounds {
+			if interval <= bound {
+				bucket = i
+				break
+			}
+		}
+		r.intervals[bucket] += 1
+
+		// Welford's online algorithm keeps the variance without storing samples
+		r.samples += 1
+		delta := float64(interval) - r.mean
+		r.mean += delta / float64(r.samples)
+		r.m2 += delta * (float64(interval) - r.mean)
+	}
+	r.last = now
+}
+
+// snapshot copies the recorded stats
+func (r *statsRecorder) snapshot() SessionStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := SessionStats{
+		Packets:      r.packets,
+		Opcodes:      make(map[Opcode]uint64, len(r.opcodes)),
+		Intervals:    append([]uint64(nil), r.intervals...),
+		IntervalMean: time.Duration(r.mean),
+	}
+	for opcode, n := range r.opcodes {
+		stats.Opcodes[opcode] = n
+	}
+	if r.samples > 1 {
+		stats.IntervalStdDev = time.Duration(math.Sqrt(r.m2 / float64(r.samples-1)))
+	}
+
+	return stats
+}
+
+// Stats returns the session's behavioral statistics, or false if the server does not record them
+func (s *Session) Stats() (SessionStats, bool) {
+	if s.stats == nil {
+		return SessionStats{}, false
+	}
+
+	return s.stats.snapshot(), true
+}
+
+// recordStats adds `packet` to the session's statistics, if the server records them
+func (s *Server) recordStats(session *Session, packet []byte) {
+	if session.stats == nil {
+		return
+	}
+
+	var opcode Opcode
+	var hasOpcode bool
+	if s.router != nil {
+		opcode, hasOpcode = s.router.Opcode(packet)
+	}
+	session.stats.record(s.now(), opcode, hasOpcode)
+}
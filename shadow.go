@@ -0,0 +1,76 @@
+package tcpserve
+
+import (
+	"io"
+	"net"
+)
+
+// shadowQueueSize is the number of packets a shadow handler may fall behind before mirrored packets are dropped
+const shadowQueueSize = 256
+
+// WithShadow returns a `ServerOption` which the Server constructor uses to modify its `onShadowPacket` member
+//
+// Every decoded packet is also mirrored to `onShadowPacket` on a shadow copy of its session whose writes are
+// discarded, so a new handler implementation can run against production traffic without affecting clients. Shadow
+// handlers run on their own goroutine per session; they never delay the real handler and their panics are contained.
+// Packets are dropped from the shadow if it falls too far behind.
+func WithShadow(onShadowPacket func(*Session, []byte)) ServerOption {
+	return func(s *Server) {
+		s.onShadowPacket = onShadowPacket
+	}
+}
+
+// A shadow replays a session's packets to the shadow handler
+type shadow struct {
+	session *Session
+	packets chan []byte
+}
+
+// startShadow creates the shadow of `session` and starts replaying packets to it
+func (s *Server) startShadow(session *Session) *shadow {
+	sh := &shadow{
+		session: NewSession(WithId(session.id), WithConn(discardConn{session.conn}), WithContext(session.Context())),
+		packets: make(chan []byte, shadowQueueSize),
+	}
+
+	go func() {
+		for packet := range sh.packets {
+			s.replayShadow(sh.session, packet)
+		}
+	}()
+
+	return sh
+}
+
+// replayShadow passes `packet` to the shadow handler, recovering from any panic
+func (s *Server) replayShadow(session *Session, packet []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logError("Shadow handler panicked", Field{"session", session.id}, Field{"panic", r})
+		}
+	}()
+
+	s.onShadowPacket(session, packet)
+}
+
+// mirror queues a copy of `packet` for the shadow handler, dropping it if the shadow is behind
+func (sh *shadow) mirror(packet []byte) {
+	select {
+	case sh.packets <- append([]byte(nil), packet...):
+	default:
+	}
+}
+
+// stop ends the shadow once its queued packets have been replayed
+func (sh *shadow) stop() {
+	close(sh.packets)
+}
+
+// discardConn is the connection of a shadow session: it reports the real connection's addresses but never touches it
+type discardConn struct {
+	net.Conn
+}
+
+func (c discardConn) Read(b []byte) (int, error)  { return 0, io.EOF }
+func (c discardConn) Write(b []byte) (int, error) { return len(b), nil }
+func (c discardConn) Close() error                { return nil }
@@ -0,0 +1,215 @@
+package tcpserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrServerClosed is reported to `onDisconnected` for sessions ended by `Stop` or `Shutdown`, and returned by `Start`
+// when stopped before it started listening
+var ErrServerClosed = errors.New("tcpserve: server closed")
+
+// A ShutdownStage is a step of the shutdown sequence that hooks can be attached to
+type ShutdownStage int
+
+const (
+	StageStopAccepting ShutdownStage = iota // The listener is closed
+	StageNotifyClients                      // Sessions got the shutdown packet and stopped reading
+	StageDrain                              // In-flight packet handlers finished, or the context expired first
+	StageForceClose                         // Remaining connections were closed
+	StageFinal                              // The server released its resources
+)
+
+func (stage ShutdownStage) String() string {
+	switch stage {
+	case StageStopAccepting:
+		return "stop-accepting"
+	case StageNotifyClients:
+		return "notify-clients"
+	case StageDrain:
+		return "drain"
+	case StageForceClose:
+		return "force-close"
+	case StageFinal:
+		return "final"
+	default:
+		return fmt.Sprintf("ShutdownStage(%d)", int(stage))
+	}
+}
+
+// A ShutdownHook runs application cleanup during shutdown, such as flushing a database or leaving service discovery
+type ShutdownHook func(ctx context.Context) error
+
+// RegisterShutdownHook attaches `hook` to `stage` of the shutdown sequence
+//
+// Hooks run right after their stage completes, in registration order. `Shutdown` passes them its context; `Stop`
+// passes `context.Background()` and, closing connections outright, only runs the stop-accepting, force-close and
+// final stages. `Shutdown` only reaches the force-close stage when its context expires. Each stage's hooks run once,
+// even when `Stop` follows `Shutdown`. Errors are logged and do not interrupt the sequence.
+func (s *Server) RegisterShutdownHook(stage ShutdownStage, hook ShutdownHook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	if s.shutdownHooks == nil {
+		s.shutdownHooks = make(map[ShutdownStage][]ShutdownHook)
+	}
+	s.shutdownHooks[stage] = append(s.shutdownHooks[stage], hook)
+}
+
+// runHooks runs the hooks attached to `stage`, unless an earlier `Stop` or `Shutdown` already did
+func (s *Server) runHooks(ctx context.Context, stage ShutdownStage) {
+	s.hooksMu.Lock()
+	if s.stagesRun[stage] {
+		s.hooksMu.Unlock()
+		return
+	}
+	if s.stagesRun == nil {
+		s.stagesRun = make(map[ShutdownStage]bool)
+	}
+	s.stagesRun[stage] = true
+	hooks := append([]ShutdownHook(nil), s.shutdownHooks[stage]...) // Hooks may register more hooks
+	s.hooksMu.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			s.logError("shutdown hook failed", Field{"stage", stage}, Field{"error", err})
+		}
+	}
+}
+
+// WithShutdownPacket returns a `ServerOption` which the Server constructor uses to modify its `shutdownPacket` member
+//
+// `Shutdown` sends `packet` to every session, through its encrypter, before waiting for them to drain.
+func WithShutdownPacket(packet []byte) ServerOption {
+	return func(s *Server) {
+		s.shutdownPacket = packet
+	}
+}
+
+// WithShutdownOrder returns a `ServerOption` which the Server constructor uses to modify its `shutdownScore` and
+// `shutdownSpacing` members
+//
+// `Shutdown` then winds sessions down from the lowest `score` to the highest, waiting `spacing` between two sessions,
+// so that for instance idle players are disconnected first and players in combat last. Sessions with equal scores
+// keep no particular order.
+func WithShutdownOrder(score func(*Session) int, spacing time.Duration) ServerOption {
+	return func(s *Server) {
+		s.shutdownScore = score
+		s.shutdownSpacing = spacing
+	}
+}
+
+// shutdownOrder returns the sessions in the order `Shutdown` winds them down
+func (s *Server) shutdownOrder() []*Session {
+	sessions := s.sessions.snapshot()
+	if s.shutdownScore == nil {
+		return sessions
+	}
+
+	scores := make(map[*Session]int, len(sessions)) // Score each session once, scores may change while sorting
+	for _, session := range sessions {
+		scores[session] = s.shutdownScore(session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return scores[sessions[i]] < scores[sessions[j]]
+	})
+
+	return sessions
+}
+
+// Shutdown gracefully stops the server: it stops accepting, sends the shutdown packet, stops reading new packets and
+// waits for in-flight packet handlers to finish before closing every connection
+//
+// Sessions are wound down in the order set by `WithShutdownOrder`, if any. If `ctx` expires first, the remaining
+// connections are force-closed and the context's error is returned once the server has shut down.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	if err = s.stopAccepting(); err != nil {
+		s.logError("error closing listener", Field{"error", err})
+	}
+	s.runHooks(ctx, StageStopAccepting)
+
+notify:
+	for i, session := range s.shutdownOrder() {
+		if i > 0 && s.shutdownSpacing > 0 {
+			timer := time.NewTimer(s.shutdownSpacing)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				break notify // Out of time, the rest is force-closed below
+			}
+		}
+
+		if s.shutdownPacket != nil {
+			session.Write(s.shutdownPacket) // Best effort, the session is closing either way
+		}
+		session.stopReading() // Stop reading once the current handler returns
+	}
+	s.runHooks(ctx, StageNotifyClients)
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		err = nil
+		s.runHooks(ctx, StageDrain)
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.runHooks(ctx, StageDrain)
+		for _, session := range s.sessions.snapshot() {
+			session.Conn().Close() // Out of time, force close whatever is left
+		}
+		<-drained
+		s.runHooks(ctx, StageForceClose)
+	}
+
+	s.finish()
+	s.runHooks(ctx, StageFinal)
+
+	return
+}
+
+// stopAccepting closes the listener and marks the server as stopping
+func (s *Server) stopAccepting() (err error) {
+	s.stopOnce.Do(func() {
+		s.lnMu.Lock()
+		defer s.lnMu.Unlock()
+
+		close(s.done)
+		err = closeListeners(s.listeners)
+	})
+
+	return
+}
+
+// stopping reports whether `Stop` or `Shutdown` has been called
+func (s *Server) stopping() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish releases the server's resources once every connection is gone, however many times the server is stopped
+func (s *Server) finish() {
+	s.finishOnce.Do(func() {
+		if clock, ok := s.clock.Load().(*coarseClock); ok {
+			clock.Stop() // Stop refreshing the coarse clock
+		}
+		if s.pool != nil {
+			s.pool.stop() // Every session has drained its queue by now
+		}
+		s.shutdownPlugins()
+		s.changes.close() // No session is left to change
+		s.events.close()
+	})
+}
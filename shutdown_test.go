@@ -0,0 +1,104 @@
+package tcpserve
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTestServer starts a server on a random local port and waits until it is listening
+func startTestServer(t *testing.T, options ...ServerOption) (*Server, *sync.WaitGroup) {
+	t.Helper()
+
+	options = append([]ServerOption{WithAddr("127.0.0.1:0"), WithOnPacket(func(*Session, []byte) {})}, options...)
+	s := NewServer(options...)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		if err := s.Start(&wg); err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for s.Addr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return s, &wg
+}
+
+func TestShutdownThenStop(t *testing.T) {
+	s, wg := startTestServer(t, WithCoarseClock(time.Millisecond), WithWorkerPool(2))
+
+	runs := make(map[ShutdownStage]int)
+	var mu sync.Mutex
+	for _, stage := range []ShutdownStage{StageStopAccepting, StageForceClose, StageFinal} {
+		stage := stage
+		s.RegisterShutdownHook(stage, func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[stage] += 1
+			return nil
+		})
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	s.Stop() // Must neither panic nor rerun the hooks
+	wg.Wait()
+
+	for stage, n := range runs {
+		if n != 1 {
+			t.Errorf("hooks of stage %s ran %d times, want 1", stage, n)
+		}
+	}
+}
+
+func TestShutdownDuringAdmission(t *testing.T) {
+	admitting := make(chan struct{})
+	release := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(admitting)
+		<-release
+		w.Write([]byte(`{"allow":true}`))
+	}))
+	defer webhook.Close()
+
+	s, wg := startTestServer(t, WithApprovalWebhook(ApprovalWebhook{URL: webhook.URL}))
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close() // The client stays connected and silent
+	<-admitting
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Shutdown(context.Background())
+	}()
+	for !s.stopping() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release) // Admit the connection after Shutdown wound the sessions down
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown() waited for a session admitted while stopping")
+	}
+	wg.Wait()
+}
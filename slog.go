@@ -0,0 +1,44 @@
+//go:build go1.21
+
+package tcpserve
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithSlog returns a `ServerOption` which the Server constructor uses to modify its `structured` member
+//
+// Log events go to `logger` with their fields as attributes, at the matching slog level. `WithLogLevel` still
+// filters events first.
+func WithSlog(logger *slog.Logger) ServerOption {
+	return WithStructuredLogger(slogLogger{logger})
+}
+
+// slogLogger adapts a `*slog.Logger` to `StructuredLogger`
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Log(level LogLevel, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+
+	l.logger.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+// slogLevel returns the slog level matching `level`
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,56 @@
+package tcpserve
+
+import (
+	"net"
+)
+
+// SocketOptions tunes the sockets of accepted connections; zero fields leave the operating system's defaults
+//
+// Options the platform does not support are silently skipped.
+type SocketOptions struct {
+	SendBuffer    int // SO_SNDBUF, in bytes
+	ReceiveBuffer int // SO_RCVBUF, in bytes
+	NotSentLowat  int // TCP_NOTSENT_LOWAT, in bytes (Linux only)
+	TOS           int // IP_TOS / IPV6_TCLASS byte used for DSCP marking (Linux only)
+}
+
+// WithSocketOptions returns a `ServerOption` which the Server constructor uses to modify its `sockopts` member
+func WithSocketOptions(options SocketOptions) ServerOption {
+	return func(s *Server) {
+		s.sockopts = &options
+	}
+}
+
+// tuneConn applies the server's socket options to `conn`, logging options that could not be set
+func (s *Server) tuneConn(conn net.Conn) {
+	if s.sockopts == nil {
+		return
+	}
+
+	for {
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapper.NetConn() // Tune the socket underneath TLS or WebSocket sessions
+	}
+
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return // Only TCP sockets are tunable
+	}
+
+	if s.sockopts.SendBuffer > 0 {
+		if err := tcp.SetWriteBuffer(s.sockopts.SendBuffer); err != nil {
+			s.logError("could not set send buffer size", Field{"remote", conn.RemoteAddr()}, Field{"error", err})
+		}
+	}
+	if s.sockopts.ReceiveBuffer > 0 {
+		if err := tcp.SetReadBuffer(s.sockopts.ReceiveBuffer); err != nil {
+			s.logError("could not set receive buffer size", Field{"remote", conn.RemoteAddr()}, Field{"error", err})
+		}
+	}
+	if err := setPlatformSockopts(tcp, s.sockopts); err != nil {
+		s.logError("could not set socket options", Field{"remote", conn.RemoteAddr()}, Field{"error", err})
+	}
+}
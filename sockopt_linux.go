@@ -0,0 +1,43 @@
+//go:build linux
+
+package tcpserve
+
+import (
+	"net"
+	"syscall"
+)
+
+// tcpNotSentLowat is TCP_NOTSENT_LOWAT, which the syscall package does not define
+const tcpNotSentLowat = 0x19
+
+// setPlatformSockopts applies the Linux-specific socket options
+func setPlatformSockopts(conn *net.TCPConn, options *SocketOptions) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// IPv6 sockets are marked through the traffic class instead of the TOS byte
+	tosLevel, tosName := syscall.IPPROTO_IP, syscall.IP_TOS
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+		tosLevel, tosName = syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS
+	}
+
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		if options.NotSentLowat > 0 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpNotSentLowat, options.NotSentLowat)
+			if sockErr != nil {
+				return
+			}
+		}
+		if options.TOS > 0 {
+			sockErr = syscall.SetsockoptInt(int(fd), tosLevel, tosName, options.TOS)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return sockErr
+}
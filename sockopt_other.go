@@ -0,0 +1,12 @@
+//go:build !linux
+
+package tcpserve
+
+import (
+	"net"
+)
+
+// setPlatformSockopts is a no-op on platforms without the Linux-specific socket options
+func setPlatformSockopts(conn *net.TCPConn, options *SocketOptions) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package tcpserve
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFrameStalled is reported to `onDisconnected` for sessions that stopped sending in the middle of a frame
+var ErrFrameStalled = errors.New("tcpserve: client stalled in the middle of a frame")
+
+// A StallFramer is a `Framer` that bounds how long a client may pause in the middle of a frame
+//
+// Framers that do not implement it leave partial frames to the idle timeout, if any.
+type StallFramer interface {
+	Framer
+	PartialFrameTimeout() time.Duration
+}
+
+// WithPartialFrameTimeout returns a `FramerOption` which the LengthPrefixFramer constructor uses to modify its
+// `stallTimeout` member
+//
+// Once part of a frame arrived, every read that brings more of it pushes the read deadline back by `d`, replacing the
+// idle timeout until the frame is complete. Slow clients that keep making progress are never cut off however large
+// the frame, while clients that stall mid-frame for `d` are disconnected with `ErrFrameStalled`.
+func WithPartialFrameTimeout(d time.Duration) FramerOption {
+	return func(f *LengthPrefixFramer) {
+		f.stallTimeout = d
+	}
+}
+
+func (f *LengthPrefixFramer) PartialFrameTimeout() time.Duration {
+	return f.stallTimeout
+}
+
+// partialFrameTimeout returns the time `framer` allows between reads of a partial frame, 0 for no limit
+func partialFrameTimeout(framer Framer) time.Duration {
+	if f, ok := framer.(StallFramer); ok {
+		return f.PartialFrameTimeout()
+	}
+
+	return 0
+}
+
+// setStallTimeout makes the session's next reads wait at most `d`, or restores the idle timeout when `d` is 0
+func (s *Session) setStallTimeout(d time.Duration) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.stallTimeout > 0 && d == 0 && s.idleTimeout <= 0 && !s.readStopped {
+		s.Conn().SetReadDeadline(time.Time{}) // Frame completed, nothing else bounds the next read
+	}
+	s.stallTimeout = d
+}
+
+// midFrame reports whether the session is waiting for the rest of a frame under a partial frame timeout
+func (s *Session) midFrame() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.stallTimeout > 0
+}
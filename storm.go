@@ -0,0 +1,113 @@
+package tcpserve
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// A ReconnectGuard slows down and then rejects remote IPs that reconnect too rapidly
+type ReconnectGuard struct {
+	Window    time.Duration // How long connection attempts from an IP are remembered
+	Threshold int           // Attempts allowed within `Window` before accepts are delayed
+	Delay     time.Duration // Delay for the first attempt over `Threshold`, doubled for every further attempt
+	MaxDelay  time.Duration // Attempts that would be delayed longer than this are rejected instead
+
+	OnStorm func(ip string, attempts int, delay time.Duration, rejected bool) // Called for every delayed or rejected attempt
+}
+
+// WithReconnectGuard returns a `ServerOption` which the Server constructor uses to modify its `storms` member
+//
+// Crash-looping clients that reconnect more than `guard.Threshold` times within `guard.Window` get escalating accept
+// delays, and are temporarily rejected once the delay would exceed `guard.MaxDelay`.
+func WithReconnectGuard(guard ReconnectGuard) ServerOption {
+	return func(s *Server) {
+		s.storms = &stormTracker{
+			guard:    guard,
+			attempts: make(map[string][]time.Time),
+		}
+	}
+}
+
+// stormTracker remembers recent connection attempts per remote IP
+type stormTracker struct {
+	guard     ReconnectGuard
+	mu        sync.Mutex
+	attempts  map[string][]time.Time // Recent attempts per IP, oldest first
+	lastSweep time.Time              // When forgotten IPs were last removed
+}
+
+// check records an attempt from `ip` and returns how long to delay it, or false if it must be rejected
+func (t *stormTracker) check(ip string, now time.Time) (attempts int, delay time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-t.guard.Window)
+	if now.Sub(t.lastSweep) > t.guard.Window {
+		t.sweep(cutoff)
+		t.lastSweep = now
+	}
+
+	recent := t.attempts[ip]
+	for len(recent) > 0 && recent[0].Before(cutoff) {
+		recent = recent[1:] // Forget attempts outside the window
+	}
+	recent = append(recent, now)
+	t.attempts[ip] = recent
+
+	attempts = len(recent)
+	if attempts <= t.guard.Threshold {
+		return attempts, 0, true
+	}
+
+	delay = t.guard.Delay
+	for i := t.guard.Threshold + 1; i < attempts && delay <= t.guard.MaxDelay; i++ {
+		delay *= 2 // Escalate for every attempt over the threshold
+	}
+
+	return attempts, delay, delay <= t.guard.MaxDelay
+}
+
+// sweep forgets every IP without attempts since `cutoff`
+func (t *stormTracker) sweep(cutoff time.Time) {
+	for ip, recent := range t.attempts {
+		if len(recent) == 0 || recent[len(recent)-1].Before(cutoff) {
+			delete(t.attempts, ip)
+		}
+	}
+}
+
+// guardReconnects applies the reconnect guard to `conn`, delaying it as needed, and returns false if it is rejected
+func (s *Server) guardReconnects(conn net.Conn) bool {
+	if s.storms == nil {
+		return true
+	}
+
+	ip := remoteIP(conn.RemoteAddr())
+	attempts, delay, ok := s.storms.check(ip, time.Now())
+	if delay == 0 {
+		return true
+	}
+
+	if s.storms.guard.OnStorm != nil {
+		s.storms.guard.OnStorm(ip, attempts, delay, !ok)
+	}
+	if !ok {
+		s.logWarn("Rejecting reconnect storm", Field{"remote", conn.RemoteAddr()}, Field{"attempts", attempts}, Field{"window", s.storms.guard.Window})
+		return false
+	}
+
+	time.Sleep(delay) // Slow the client down before doing any work for it
+
+	return true
+}
+
+// remoteIP returns the IP part of `addr`, or the whole address if it has no port
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
@@ -0,0 +1,39 @@
+package tcpserve
+
+// A CodecSet holds replacement codecs for `SwapCodecs`; nil fields keep the session's current codec
+type CodecSet struct {
+	Framer  Framer
+	Encrypt Codec
+	Decrypt Codec
+}
+
+// SwapCodecs replaces the session's codecs at a precise frame boundary in both directions
+//
+// On the write side, `syncPoint` (if not nil) is sent with the old codecs and the swap happens before any other write
+// can start, so every later write uses the new codecs. On the read side, the new codecs apply from the frame after
+// the one being handled, provided SwapCodecs is called from the session's packet callback, typically while handling
+// the negotiation packet. Bytes already received but not yet framed are split with the new framer. With a worker
+// pool, the read side only switches at that frame if `WithCodecBarrier` marks the packet being handled.
+func (s *Session) SwapCodecs(codecs CodecSet, syncPoint []byte) error {
+	s.codecMu.Lock()
+	defer s.codecMu.Unlock()
+
+	if syncPoint != nil {
+		if _, err := s.write(syncPoint); err != nil {
+			return err // Keep the old codecs, the peer never saw the sync point
+		}
+	}
+
+	if codecs.Framer != nil {
+		s.framer = codecs.Framer
+	}
+	if codecs.Encrypt != nil {
+		s.encrypt = codecs.Encrypt
+		s.appendEncrypt = nil // The new encrypter takes over from any pooled one
+	}
+	if codecs.Decrypt != nil {
+		s.decrypt = codecs.Decrypt
+	}
+
+	return nil
+}
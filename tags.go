@@ -0,0 +1,46 @@
+package tcpserve
+
+// WithTags returns a `SessionOption` which the Session constructor uses to modify its `tags` member
+func WithTags(tags map[string]string) SessionOption {
+	return func(s *Session) {
+		s.tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			s.tags[k] = v
+		}
+	}
+}
+
+// Tag returns the value of the session's tag `key`
+func (s *Session) Tag(key string) (string, bool) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	v, ok := s.tags[key]
+
+	return v, ok
+}
+
+// SetTag sets the session's tag `key` to `value`
+func (s *Session) SetTag(key, value string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.tags == nil {
+		s.tags = make(map[string]string)
+	}
+	s.tags[key] = value
+	s.changes.publish(RegistryDelta{Kind: DeltaTag, Session: s.id, Key: key, Value: value})
+}
+
+// Tags returns a copy of all of the session's tags
+func (s *Session) Tags() map[string]string {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+
+	return tags
+}
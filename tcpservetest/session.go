@@ -0,0 +1,141 @@
+// Package tcpservetest provides utilities for unit testing tcpserve handlers without a live Server or real sockets
+package tcpservetest
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/matthieutran/tcpserve"
+)
+
+// A FakeConn is an in-memory `net.Conn` which serves scripted reads and captures every write
+type FakeConn struct {
+	mu      sync.Mutex
+	reads   [][]byte      // Scripted reads, served in order
+	writes  [][]byte      // Copies of everything written to the connection
+	latency time.Duration // Simulated delay applied to every read and write
+	closed  bool
+}
+
+// NewFakeSession creates a Session backed by a `FakeConn` and returns both
+//
+// `options` are applied after the fake connection, so codecs and IDs can be set as they would be by a Server.
+func NewFakeSession(options ...tcpserve.SessionOption) (*tcpserve.Session, *FakeConn) {
+	conn := &FakeConn{}
+	session := tcpserve.NewSession(append([]tcpserve.SessionOption{tcpserve.WithConn(conn)}, options...)...)
+
+	return session, conn
+}
+
+// WithValues returns a `SessionOption` which stores `values` in the session as handlers would with `Session.Set`
+//
+// Pass it to `NewFakeSession` to start a handler test from a session already carrying state, such as a login.
+func WithValues(values map[string]interface{}) tcpserve.SessionOption {
+	return func(s *tcpserve.Session) {
+		for key, v := range values {
+			s.Set(key, v)
+		}
+	}
+}
+
+// Script queues packets to be returned by subsequent reads
+func (c *FakeConn) Script(packets ...[]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads = append(c.reads, packets...)
+}
+
+// Writes returns every packet written to the connection so far, in order
+func (c *FakeConn) Writes() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([][]byte(nil), c.writes...)
+}
+
+// SetLatency sets a delay applied to every read and write, simulating a slow network
+func (c *FakeConn) SetLatency(latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.latency = latency
+}
+
+// Closed reports whether the connection has been closed
+func (c *FakeConn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// Read serves the next scripted packet, returning `io.EOF` once the script is exhausted
+func (c *FakeConn) Read(b []byte) (int, error) {
+	c.delay()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if len(c.reads) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, c.reads[0])
+	if n < len(c.reads[0]) {
+		c.reads[0] = c.reads[0][n:] // Serve the rest of the packet on the next read
+	} else {
+		c.reads = c.reads[1:]
+	}
+
+	return n, nil
+}
+
+// Write captures a copy of `b`
+func (c *FakeConn) Write(b []byte) (int, error) {
+	c.delay()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	c.writes = append(c.writes, append([]byte(nil), b...))
+
+	return len(b), nil
+}
+
+// Close marks the connection as closed; further reads and writes fail with `net.ErrClosed`
+func (c *FakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	return nil
+}
+
+func (c *FakeConn) LocalAddr() net.Addr                { return fakeAddr("local") }
+func (c *FakeConn) RemoteAddr() net.Addr               { return fakeAddr("remote") }
+func (c *FakeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *FakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *FakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// delay sleeps for the configured latency
+func (c *FakeConn) delay() {
+	c.mu.Lock()
+	latency := c.latency
+	c.mu.Unlock()
+
+	time.Sleep(latency)
+}
+
+// fakeAddr is the address reported by both ends of a `FakeConn`
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "fake" }
+func (a fakeAddr) String() string  { return string(a) }
@@ -0,0 +1,51 @@
+package tcpservetest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFakeSessionRecordsWrites(t *testing.T) {
+	session, conn := NewFakeSession()
+
+	for _, packet := range [][]byte{[]byte("hello"), []byte("world")} {
+		if _, err := session.Write(packet); err != nil {
+			t.Fatalf("Write(%q) error = %v", packet, err)
+		}
+	}
+
+	writes := conn.Writes()
+	if len(writes) != 2 {
+		t.Fatalf("recorded %d writes, want 2", len(writes))
+	}
+	if !bytes.HasSuffix(writes[0], []byte("hello")) || !bytes.HasSuffix(writes[1], []byte("world")) {
+		t.Errorf("recorded %q, want the framed packets in order", writes)
+	}
+}
+
+func TestFakeSessionValues(t *testing.T) {
+	session, _ := NewFakeSession(WithValues(map[string]interface{}{"login": "alice"}))
+
+	if v, ok := session.Get("login"); !ok || v != "alice" {
+		t.Errorf("Get(%q) = %v, %v, want %q, true", "login", v, ok, "alice")
+	}
+	if _, ok := session.Get("character"); ok {
+		t.Errorf("Get(%q) found a value never set", "character")
+	}
+}
+
+func TestFakeConnScriptedReads(t *testing.T) {
+	_, conn := NewFakeSession()
+	conn.Script([]byte("abc"))
+
+	b := make([]byte, 2)
+	if n, err := conn.Read(b); err != nil || string(b[:n]) != "ab" {
+		t.Fatalf("Read = %q, %v, want %q", b[:n], err, "ab")
+	}
+	if n, err := conn.Read(b); err != nil || string(b[:n]) != "c" {
+		t.Fatalf("Read = %q, %v, want the rest of the packet", b[:n], err)
+	}
+	if _, err := conn.Read(b); err == nil {
+		t.Error("Read past the script succeeded, want io.EOF")
+	}
+}
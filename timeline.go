@@ -0,0 +1,81 @@
+package tcpserve
+
+import (
+	"sync"
+	"time"
+)
+
+// Kinds of the events the server records on session timelines by itself
+const (
+	EventConnect    = "connect"    // The session was accepted; the detail is the remote address
+	EventThrottle   = "throttle"   // A packet was dropped by `WithRateLimit`
+	EventClose      = "close"      // `Session.Close` was called
+	EventDisconnect = "disconnect" // The session ended; the detail is the reason
+)
+
+// A TimelineEvent is one entry of a session's timeline
+type TimelineEvent struct {
+	At     time.Time
+	Kind   string // What happened, one of the `Event` constants or a kind of the application's own
+	Detail string
+}
+
+// WithTimeline returns a `ServerOption` which the Server constructor uses to modify its `timelineSize` member
+//
+// Every session then keeps its last `size` events, recorded by the server and by handlers through `Session.Record`,
+// so `onDisconnected` can read `Session.Timeline` to explain why a client was disconnected.
+func WithTimeline(size int) ServerOption {
+	return func(s *Server) {
+		s.timelineSize = size
+	}
+}
+
+// timeline is a bounded, concurrency-safe log of a session's events
+type timeline struct {
+	mu     sync.Mutex
+	events []TimelineEvent // Ring buffer of the latest events
+	next   int             // Where the next event goes
+	full   bool            // Whether `events` has wrapped around
+}
+
+// newTimeline creates a timeline keeping the last `size` events
+func newTimeline(size int) *timeline {
+	return &timeline{events: make([]TimelineEvent, size)}
+}
+
+// Record adds an event to the session's timeline, such as a finished handshake, a state change or a violation
+//
+// It does nothing unless the server was created with `WithTimeline`.
+func (s *Session) Record(kind, detail string) {
+	t := s.timeline
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events[t.next] = TimelineEvent{At: time.Now(), Kind: kind, Detail: detail}
+	t.next += 1
+	if t.next == len(t.events) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+// Timeline returns the session's recorded events, oldest first
+func (s *Session) Timeline() []TimelineEvent {
+	t := s.timeline
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		return append([]TimelineEvent(nil), t.events[:t.next]...)
+	}
+
+	return append(append([]TimelineEvent(nil), t.events[t.next:]...), t.events[:t.next]...)
+}
@@ -0,0 +1,59 @@
+package tcpserve
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+)
+
+// tlsHandshakeTimeout bounds the handshake of `UpgradeTLS`, so a silent peer can't hold the session's writes forever
+const tlsHandshakeTimeout = 10 * time.Second
+
+// UpgradeTLS performs a server-side TLS handshake over the session's current connection and swaps it for the
+// resulting TLS connection, STARTTLS-style
+//
+// Writes are held off for the duration of the handshake, which gives up after `tlsHandshakeTimeout` or when the
+// session ends; the plaintext connection can still be closed meanwhile, by `Stop` for instance. UpgradeTLS must be
+// called from the session's own packet callback, right after the packet that announces the upgrade, so no read is
+// in flight on the plaintext connection; with a worker pool, mark that packet with `WithCodecBarrier`. If the
+// handshake fails the session keeps its plaintext connection, which is usually unusable afterwards.
+func (s *Session) UpgradeTLS(config *tls.Config) error {
+	s.tlsMu.Lock()
+	defer s.tlsMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(s.Context(), tlsHandshakeTimeout)
+	defer cancel()
+
+	conn := tls.Server(s.Conn(), config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+
+	s.connMu.Lock()
+	s.conn = conn
+	s.connMu.Unlock()
+
+	return nil
+}
+
+// WithTLS returns a `ServerOption` which the Server constructor uses to modify its `tlsConfig` member
+//
+// Every accepted connection then performs a TLS handshake before it becomes a session's connection.
+func WithTLS(config *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConfig = config
+	}
+}
+
+// LoadTLSConfig creates a server TLS configuration from a PEM encoded certificate and key pair
+func LoadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
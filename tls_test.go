@@ -0,0 +1,40 @@
+package tcpserve
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUpgradeTLSSilentPeerCanBeClosed(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	session := NewSession(WithConn(server))
+	upgraded := make(chan error, 1)
+	go func() {
+		upgraded <- session.UpgradeTLS(&tls.Config{})
+	}()
+
+	time.Sleep(10 * time.Millisecond) // Let the handshake wait for a ClientHello that never comes
+	closed := make(chan struct{})
+	go func() {
+		session.Conn().Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Conn() blocked behind the TLS handshake")
+	}
+	select {
+	case err := <-upgraded:
+		if err == nil {
+			t.Fatal("UpgradeTLS() succeeded on a closed connection")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("UpgradeTLS() did not give up once the connection closed")
+	}
+}
@@ -0,0 +1,43 @@
+package tcpserve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+)
+
+// traceSession starts a runtime/trace task spanning the lifetime of session `id` and labels the calling goroutine with
+// the session's ID, so `go tool trace` and profiles can attribute work to individual sessions
+//
+// The returned function ends the task and must be called when the session is done.
+func traceSession(ctx context.Context, id int, conn net.Conn) (context.Context, func()) {
+	ctx, task := trace.NewTask(ctx, "tcpserve.session")
+	ctx = pprof.WithLabels(ctx, pprof.Labels("tcpserve.session", strconv.Itoa(id)))
+	pprof.SetGoroutineLabels(ctx) // Name the connection goroutine after its session
+
+	if trace.IsEnabled() {
+		trace.Logf(ctx, "tcpserve", "session %d connected from %s", id, conn.RemoteAddr())
+	}
+
+	return ctx, task.End
+}
+
+// handlerRegion returns the name of the trace region running the handler of `packet`
+//
+// With a router, each opcode gets its own region type, such as "tcpserve.onPacket 0x0012", so `go tool trace` breaks
+// handler latency down by opcode. The name is only built while tracing.
+func (s *Server) handlerRegion(packet []byte) string {
+	if !trace.IsEnabled() || s.router == nil {
+		return "tcpserve.onPacket"
+	}
+
+	opcode, ok := s.router.Opcode(packet)
+	if !ok {
+		return "tcpserve.onPacket"
+	}
+
+	return fmt.Sprintf("tcpserve.onPacket 0x%04X", uint16(opcode))
+}
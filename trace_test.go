@@ -0,0 +1,31 @@
+package tcpserve
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+)
+
+func TestHandlerRegionNamesOpcode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skipf("tracing unavailable: %v", err)
+	}
+	defer trace.Stop()
+
+	tests := []struct {
+		name    string
+		server  *Server
+		packet  []byte
+		wantReg string
+	}{
+		{name: "routed", server: NewServer(WithRouter(NewRouter())), packet: []byte{0x12, 0x00, 'x'}, wantReg: "tcpserve.onPacket 0x0012"},
+		{name: "too short for an opcode", server: NewServer(WithRouter(NewRouter())), packet: []byte{0x12}, wantReg: "tcpserve.onPacket"},
+		{name: "no router", server: NewServer(), packet: []byte{0x12, 0x00}, wantReg: "tcpserve.onPacket"},
+	}
+	for _, test := range tests {
+		if got := test.server.handlerRegion(test.packet); got != test.wantReg {
+			t.Errorf("%s: region = %q, want %q", test.name, got, test.wantReg)
+		}
+	}
+}
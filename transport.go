@@ -0,0 +1,287 @@
+package tcpserve
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pushQueueSize is the number of pushed chunks a `PushConn` buffers ahead of the session's reads
+const pushQueueSize = 64
+
+// A Transport creates the listener the server accepts connections from, in place of a TCP socket
+//
+// Blocking network stacks can hand back their own `net.Listener`. Readiness-based libraries such as gnet or netpoll,
+// which call back with data instead of offering blocking connections, bridge into the server with a `PushListener`:
+// the adapter opens a `PushConn` when the library reports a new connection, pushes the bytes it reports, and hangs
+// it up when the library reports a close. The framer, codecs, router and every handler then work unchanged.
+type Transport interface {
+	Listen(addr string) (net.Listener, error) // `addr` is the server's ":port" address
+}
+
+// WithTransport returns a `ServerOption` which the Server constructor uses to modify its `transport` member
+func WithTransport(transport Transport) ServerOption {
+	return func(s *Server) {
+		s.transport = transport
+	}
+}
+
+// A PushListener is a `net.Listener` whose connections are opened by a transport adapter rather than accepted
+type PushListener struct {
+	addr      net.Addr
+	accept    chan *PushConn // Opened connections waiting for `Accept`
+	done      chan struct{}  // Closed by `Close`
+	closeOnce sync.Once
+	onClose   func() error // Releases the adapter's resources, if set
+}
+
+// NewPushListener creates a `PushListener` reporting `addr` as its address, holding up to `backlog` connections
+// opened but not accepted yet
+//
+// `onClose`, if set, is called once when the server closes the listener, to stop the underlying library.
+func NewPushListener(addr net.Addr, backlog int, onClose func() error) *PushListener {
+	return &PushListener{
+		addr:    addr,
+		accept:  make(chan *PushConn, backlog),
+		done:    make(chan struct{}),
+		onClose: onClose,
+	}
+}
+
+// Open hands a new connection from `remote` to the server, reporting false if the backlog is full or the listener
+// is closed
+//
+// `write` sends bytes to the peer and `close` closes the underlying connection; both are called by the session.
+func (l *PushListener) Open(remote net.Addr, write func([]byte) (int, error), close func() error) (*PushConn, bool) {
+	conn := &PushConn{
+		local:   l.addr,
+		remote:  remote,
+		write:   write,
+		close:   close,
+		chunks:  make(chan []byte, pushQueueSize),
+		hangup:  make(chan struct{}),
+		closed:  make(chan struct{}),
+		changed: make(chan struct{}, 1),
+		seen:    time.Now(),
+	}
+
+	select {
+	case <-l.done:
+		return nil, false
+	default:
+	}
+
+	select {
+	case l.accept <- conn:
+		return conn, true
+	default:
+		return nil, false
+	}
+}
+
+func (l *PushListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accept:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *PushListener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = nil
+		if l.onClose != nil {
+			err = l.onClose()
+		}
+	})
+
+	return err
+}
+
+func (l *PushListener) Addr() net.Addr {
+	return l.addr
+}
+
+// A PushConn is a `net.Conn` whose incoming bytes are pushed by a transport adapter
+type PushConn struct {
+	local, remote net.Addr
+	write         func([]byte) (int, error)
+	close         func() error
+
+	chunks    chan []byte   // Pushed bytes waiting to be read
+	pending   []byte        // Rest of a chunk larger than the last read's buffer
+	hangup    chan struct{} // Closed by `Hangup`
+	hangOnce  sync.Once
+	closed    chan struct{} // Closed by `Close`
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	seen     time.Time     // When bytes were last pushed
+	deadline time.Time     // Read deadline, if any
+	changed  chan struct{} // Wakes a blocked read when the deadline changes
+}
+
+// Push queues a copy of `data` for the session, waiting while the session is behind; it reports false once the
+// connection is closed
+//
+// Stream transports should use Push, which never drops bytes.
+func (c *PushConn) Push(data []byte) bool {
+	c.touch()
+
+	select {
+	case c.chunks <- append([]byte(nil), data...):
+		return true
+	case <-c.closed:
+		return false
+	}
+}
+
+// TryPush queues a copy of `data` like `Push`, but drops it and reports false if the session is behind
+//
+// Datagram transports may use TryPush, as losing a datagram is allowed there.
+func (c *PushConn) TryPush(data []byte) bool {
+	c.touch()
+
+	select {
+	case c.chunks <- append([]byte(nil), data...):
+		return true
+	default:
+		return false
+	}
+}
+
+// Hangup reports that the peer closed the connection: reads fail with `io.EOF` once the pushed bytes are read
+func (c *PushConn) Hangup() {
+	c.hangOnce.Do(func() {
+		close(c.hangup)
+	})
+}
+
+// touch records activity on the connection
+func (c *PushConn) touch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen = time.Now()
+}
+
+// lastPush returns when bytes were last pushed
+func (c *PushConn) lastPush() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.seen
+}
+
+// Read reads pushed bytes, never more than one chunk's worth per call
+func (c *PushConn) Read(p []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
+	for {
+		c.mu.Lock()
+		deadline := c.deadline
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		var chunk []byte
+		var err error
+		select {
+		case chunk = <-c.chunks:
+		case <-c.closed:
+			err = io.EOF
+		case <-c.hangup:
+			select {
+			case chunk = <-c.chunks: // Read what the peer sent before hanging up
+			default:
+				err = io.EOF
+			}
+		case <-expired:
+		case <-c.changed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) > 0 {
+			n := copy(p, chunk)
+			c.pending = chunk[n:]
+			return n, nil
+		}
+	}
+}
+
+// midMessage reports whether the last read left part of its chunk to the next one
+func (c *PushConn) midMessage() bool {
+	return len(c.pending) > 0
+}
+
+func (c *PushConn) Write(p []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	return c.write(p)
+}
+
+func (c *PushConn) Close() (err error) {
+	err = net.ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.close()
+	})
+
+	return
+}
+
+func (c *PushConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *PushConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *PushConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *PushConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+
+	select {
+	case c.changed <- struct{}{}: // Let a blocked read pick the new deadline up
+	default:
+	}
+
+	return nil
+}
+
+func (c *PushConn) SetWriteDeadline(t time.Time) error {
+	return nil // Writes are up to the adapter's library
+}
@@ -0,0 +1,126 @@
+package tcpserve
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	udpDatagramSize = 65535 // Largest datagram the UDP listener reads
+	udpAcceptQueue  = 128   // New remote addresses waiting for `Accept` before their datagrams are dropped
+)
+
+// WithUDP returns a `ServerOption` which the Server constructor uses to modify its `udp` and `udpIdle` members
+//
+// The server then listens on a UDP socket instead of TCP and treats every remote address as a session, running its
+// datagrams through the usual framer, decrypter and packet handlers; with the default framer each datagram is one
+// packet. Writes are sent as one datagram each. Sessions silent for `idle` are closed, as UDP has no disconnect.
+// Datagrams are dropped, as UDP allows, when a session falls behind.
+func WithUDP(idle time.Duration) ServerOption {
+	return func(s *Server) {
+		s.udp = true
+		s.udpIdle = idle
+	}
+}
+
+// udpListener demultiplexes the datagrams of a packet socket into one `PushConn` per remote address
+type udpListener struct {
+	*PushListener
+	pc    net.PacketConn
+	idle  time.Duration
+	mu    sync.Mutex
+	conns map[string]*PushConn // Live connections, keyed by remote address
+}
+
+// newUDPListener serves `pc` as a listener, closing connections silent for `idle`
+func newUDPListener(pc net.PacketConn, idle time.Duration) *udpListener {
+	l := &udpListener{
+		PushListener: NewPushListener(pc.LocalAddr(), udpAcceptQueue, pc.Close),
+		pc:           pc,
+		idle:         idle,
+		conns:        make(map[string]*PushConn),
+	}
+
+	go l.read()
+	go l.expire()
+
+	return l
+}
+
+// read hands every datagram to the connection of its sender, creating connections for new senders
+func (l *udpListener) read() {
+	buf := make([]byte, udpDatagramSize)
+	for {
+		n, addr, err := l.pc.ReadFrom(buf)
+		if err != nil {
+			l.Close() // Socket is unusable or was closed
+			return
+		}
+
+		conn, ok := l.conn(addr)
+		if !ok {
+			continue // Accept queue is full, drop the datagram
+		}
+		conn.TryPush(buf[:n])
+	}
+}
+
+// conn returns the connection of `addr`, opening it if the address is new
+func (l *udpListener) conn(addr net.Addr) (*PushConn, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := addr.String()
+	if conn, ok := l.conns[key]; ok {
+		return conn, true
+	}
+
+	var conn *PushConn
+	write := func(p []byte) (int, error) {
+		return l.pc.WriteTo(p, addr)
+	}
+	forget := func() error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.conns[key] == conn {
+			delete(l.conns, key) // The address starts a new session next time
+		}
+
+		return nil
+	}
+
+	conn, ok := l.Open(addr, write, forget)
+	if ok {
+		l.conns[key] = conn
+	}
+
+	return conn, ok
+}
+
+// expire closes the connections that stayed silent for longer than the idle timeout
+func (l *udpListener) expire() {
+	ticker := time.NewTicker(l.idle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case now := <-ticker.C:
+			var idle []*PushConn
+			l.mu.Lock()
+			for _, conn := range l.conns {
+				if now.Sub(conn.lastPush()) > l.idle {
+					idle = append(idle, conn)
+				}
+			}
+			l.mu.Unlock()
+
+			for _, conn := range idle {
+				conn.Close() // The session sees the end of the stream
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package tcpserve
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPLargeDatagramIsOnePacket(t *testing.T) {
+	packets := make(chan []byte, 4)
+	s, wg := startTestServer(t, WithUDP(time.Minute), WithOnPacket(func(_ *Session, packet []byte) {
+		packets <- append([]byte(nil), packet...)
+	}))
+	defer wg.Wait()
+	defer s.Stop()
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	datagram := bytes.Repeat([]byte("0123456789"), 300) // Larger than a single read
+	if _, err := conn.Write(datagram); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	select {
+	case packet := <-packets:
+		if !bytes.Equal(packet, datagram[legacyHeaderSize:]) {
+			t.Fatalf("packet of %d bytes, want %d", len(packet), len(datagram)-legacyHeaderSize)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("datagram was not delivered")
+	}
+
+	select {
+	case packet := <-packets:
+		t.Errorf("datagram split into more packets, got another of %d bytes", len(packet))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
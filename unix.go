@@ -0,0 +1,51 @@
+package tcpserve
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+// WithUnixSocket returns a `ServerOption` which the Server constructor uses to modify its `unixPath` and `unixPerms` members
+//
+// The server then listens on a Unix domain socket at `path` instead of a TCP port, for local IPC such as a login
+// server talking to channel servers on the same host. The socket file gets `perms`. A stale socket file left behind
+// by a crashed server is removed on start, and the socket file is removed again when the server stops.
+func WithUnixSocket(path string, perms os.FileMode) ServerOption {
+	return func(s *Server) {
+		s.unixPath = path
+		s.unixPerms = perms
+	}
+}
+
+// listenUnix binds the server's Unix domain socket
+func (s *Server) listenUnix() (net.Listener, error) {
+	removeStaleSocket(s.unixPath)
+
+	ln, err := net.Listen("unix", s.unixPath) // Unlinks the socket file on close
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(s.unixPath, s.unixPerms); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket file at `path` if no server is listening on it anymore
+func removeStaleSocket(path string) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return // Nothing there, or not ours to remove
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close() // A live server owns it, let the bind fail
+		return
+	}
+
+	os.Remove(path)
+}
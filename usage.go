@@ -0,0 +1,174 @@
+package tcpserve
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OpcodeUsage summarizes the traffic of a single opcode since the router was created
+type OpcodeUsage struct {
+	Opcode     Opcode        `json:"opcode"`
+	Registered bool          `json:"registered"` // Whether a handler is registered; registered opcodes without packets are dead handlers
+	Packets    uint64        `json:"packets"`    // Packets received, handled or not
+	Bytes      uint64        `json:"bytes"`      // Bytes of those packets, opcode included
+	Unhandled  uint64        `json:"unhandled"`  // Packets without a handler
+	Panics     uint64        `json:"panics"`     // Packets whose handler panicked
+	Latency    time.Duration `json:"latency"`    // Time spent in the handler, in total
+	MaxLatency time.Duration `json:"maxLatency"` // Longest single handler run
+}
+
+// AverageLatency returns the average time the handler took per handled packet
+func (u OpcodeUsage) AverageLatency() time.Duration {
+	handled := u.Packets - u.Unhandled
+	if handled == 0 {
+		return 0
+	}
+
+	return u.Latency / time.Duration(handled)
+}
+
+// ErrorRate returns the share of packets that were unhandled or made their handler panic
+func (u OpcodeUsage) ErrorRate() float64 {
+	if u.Packets == 0 {
+		return 0
+	}
+
+	return float64(u.Unhandled+u.Panics) / float64(u.Packets)
+}
+
+// WithUsageStats returns a `RouterOption` which the Router constructor uses to modify its `usage` member
+//
+// The router then counts packets, bytes, errors and handler latency per opcode, see `Router.Usage`.
+func WithUsageStats() RouterOption {
+	return func(r *Router) {
+		r.usage = &usageStats{opcodes: make(map[Opcode]*OpcodeUsage)}
+	}
+}
+
+// usageStats holds the per-opcode counters of `WithUsageStats`
+type usageStats struct {
+	mu      sync.Mutex
+	opcodes map[Opcode]*OpcodeUsage
+}
+
+// record counts one packet of `opcode` and `size` bytes, handled in `latency` unless it was unhandled
+func (u *usageStats) record(opcode Opcode, size int, handled, panicked bool, latency time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage, ok := u.opcodes[opcode]
+	if !ok {
+		usage = &OpcodeUsage{Opcode: opcode}
+		u.opcodes[opcode] = usage
+	}
+
+	usage.Packets += 1
+	usage.Bytes += uint64(size)
+	if !handled {
+		usage.Unhandled += 1
+		return
+	}
+	if panicked {
+		usage.Panics += 1
+	}
+	usage.Latency += latency
+	if latency > usage.MaxLatency {
+		usage.MaxLatency = latency
+	}
+}
+
+// dispatchCounted runs `handler` and records it in the usage stats, even when it panics
+func (r *Router) dispatchCounted(opcode Opcode, handler HandlerFunc, s *Session, packet []byte) {
+	start := time.Now()
+	panicked := true
+	defer func() {
+		r.usage.record(opcode, len(packet), true, panicked, time.Since(start)) // The panic itself keeps going up
+	}()
+
+	handler(s, packet[r.opcodeSize:])
+	panicked = false
+}
+
+// Usage returns the usage of every opcode seen or registered, sorted by opcode; it is nil without `WithUsageStats`
+func (r *Router) Usage() []OpcodeUsage {
+	if r.usage == nil {
+		return nil
+	}
+
+	r.usage.mu.Lock()
+	byOpcode := make(map[Opcode]OpcodeUsage, len(r.usage.opcodes))
+	for opcode, usage := range r.usage.opcodes {
+		byOpcode[opcode] = *usage
+	}
+	r.usage.mu.Unlock()
+
+	r.mu.RLock()
+	for opcode := range r.handlers {
+		usage := byOpcode[opcode]
+		usage.Opcode = opcode
+		usage.Registered = true
+		byOpcode[opcode] = usage
+	}
+	r.mu.RUnlock()
+
+	report := make([]OpcodeUsage, 0, len(byOpcode))
+	for _, usage := range byOpcode {
+		report = append(report, usage)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Opcode < report[j].Opcode
+	})
+
+	return report
+}
+
+// ReportUsage calls `report` with the router's `Usage` every `interval`, until `ctx` ends
+//
+// `report` typically writes the usage to a file with `WriteUsageCSV` or `WriteUsageJSON`.
+func (r *Router) ReportUsage(ctx context.Context, interval time.Duration, report func([]OpcodeUsage)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report(r.Usage())
+		}
+	}
+}
+
+// WriteUsageCSV writes `usage` to `w` as CSV with a header row, latencies in microseconds
+func WriteUsageCSV(w io.Writer, usage []OpcodeUsage) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"opcode", "registered", "packets", "bytes", "unhandled", "panics", "error_rate", "avg_latency_us", "max_latency_us"})
+	for _, u := range usage {
+		cw.Write([]string{
+			fmt.Sprintf("0x%X", uint16(u.Opcode)),
+			strconv.FormatBool(u.Registered),
+			strconv.FormatUint(u.Packets, 10),
+			strconv.FormatUint(u.Bytes, 10),
+			strconv.FormatUint(u.Unhandled, 10),
+			strconv.FormatUint(u.Panics, 10),
+			strconv.FormatFloat(u.ErrorRate(), 'f', 4, 64),
+			strconv.FormatInt(u.AverageLatency().Microseconds(), 10),
+			strconv.FormatInt(u.MaxLatency.Microseconds(), 10),
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteUsageJSON writes `usage` to `w` as a JSON array, latencies in nanoseconds
+func WriteUsageJSON(w io.Writer, usage []OpcodeUsage) error {
+	return json.NewEncoder(w).Encode(usage)
+}
@@ -0,0 +1,186 @@
+package tcpserve
+
+import (
+	"errors"
+	"strings"
+)
+
+// OptionErrors lists every problem found in a server's configuration
+type OptionErrors []error
+
+func (e OptionErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return "tcpserve: invalid server options: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the server's options for missing prerequisites and conflicting combinations
+//
+// It returns nil or an `OptionErrors` describing every problem found. `Start` validates the server before binding.
+func (s *Server) Validate() error {
+	var errs OptionErrors
+
+	if s.onPacket == nil && s.onRawPacket == nil && s.router == nil && !s.events.enabled() {
+		errs = append(errs, errors.New("no packet handler set (use WithOnPacket, WithOnRawPacket, WithRouter or Events)"))
+	}
+	errs = append(errs, s.checkOptions()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// checkOptions returns the problems of the server's option values and combinations
+//
+// Unlike `Validate`, it does not require a packet handler, which `Events` can stand in for after construction.
+func (s *Server) checkOptions() (errs OptionErrors) {
+	if s.onRawPacket != nil && s.router != nil {
+		errs = append(errs, errors.New("WithOnRawPacket and WithRouter are exclusive; the router would never be used"))
+	}
+	if s.onRawPacket != nil && len(s.middleware) > 0 {
+		errs = append(errs, errors.New("WithMiddleware does not apply to WithOnRawPacket handlers"))
+	}
+	if s.onRawPacket != nil && len(s.groupMiddleware) > 0 {
+		errs = append(errs, errors.New("WithGroupMiddleware does not apply to WithOnRawPacket handlers"))
+	}
+	if s.router != nil && s.router.opcodeSize != 1 && s.router.opcodeSize != 2 {
+		errs = append(errs, errors.New("router opcode size must be 1 or 2 bytes"))
+	}
+	if s.onPacket != nil && s.onRawPacket != nil {
+		errs = append(errs, errors.New("WithOnPacket and WithOnRawPacket are exclusive; onPacket would never be called"))
+	}
+	if s.port < 0 || s.port > 65535 {
+		errs = append(errs, errors.New("port must be between 0 and 65535"))
+	}
+	if s.bindRetries < 0 || s.bindDelay < 0 {
+		errs = append(errs, errors.New("WithBindRetry needs at least one attempt and a non-negative delay"))
+	}
+	if s.inherited != "" && s.bindRetries > 0 {
+		errs = append(errs, errors.New("WithBindRetry has no effect on an inherited listener, which is already bound"))
+	}
+	if s.clockResolution < 0 {
+		errs = append(errs, errors.New("WithCoarseClock resolution must be positive"))
+	}
+
+	if s.versionGate != nil && s.versionGate.Extract == nil {
+		errs = append(errs, errors.New("WithVersionGate needs an Extract function"))
+	}
+
+	if s.storms != nil && (s.storms.guard.Window <= 0 || s.storms.guard.Delay <= 0) {
+		errs = append(errs, errors.New("WithReconnectGuard needs a positive window and delay"))
+	}
+
+	if s.approval != nil && s.approval.URL == "" {
+		errs = append(errs, errors.New("WithApprovalWebhook needs a URL"))
+	}
+
+	if s.tlsConfig != nil && len(s.tlsConfig.Certificates) == 0 && s.tlsConfig.GetCertificate == nil && s.tlsConfig.GetConfigForClient == nil {
+		errs = append(errs, errors.New("WithTLS needs a configuration with a certificate"))
+	}
+
+	if len(s.flaggedFramers) > 0 && s.flags == nil {
+		errs = append(errs, errors.New("WithFlaggedFramer needs WithFeatureFlags to decide which sessions get it"))
+	}
+
+	if s.latency != nil && (s.latency.min < 0 || s.latency.max < s.latency.min || s.latency.jitter < 0) {
+		errs = append(errs, errors.New("WithSimulatedLatency needs 0 <= min <= max and a non-negative jitter"))
+	}
+
+	if s.workers < 0 {
+		errs = append(errs, errors.New("WithWorkerPool needs at least one worker"))
+	}
+
+	if s.idleTimeout < 0 {
+		errs = append(errs, errors.New("WithIdleTimeout needs a positive duration"))
+	}
+
+	if hb := s.heartbeat; hb != nil {
+		if hb.interval <= 0 || hb.timeout <= 0 {
+			errs = append(errs, errors.New("WithHeartbeat needs a positive interval and timeout"))
+		}
+		if hb.buildPing == nil {
+			errs = append(errs, errors.New("WithHeartbeat needs a ping builder"))
+		}
+	}
+
+	if cc := s.cryptoCheck; cc != nil {
+		if cc.Interval <= 0 || cc.Timeout <= 0 {
+			errs = append(errs, errors.New("WithCryptoCheck needs a positive interval and timeout"))
+		}
+		if cc.Build == nil || cc.Echo == nil {
+			errs = append(errs, errors.New("WithCryptoCheck needs Build and Echo functions"))
+		}
+	}
+
+	if s.fanOutWorkers < 1 || s.fanOutChunk < 1 {
+		errs = append(errs, errors.New("WithBroadcastParallelism needs at least one worker and one session per chunk"))
+	}
+
+	if s.registryShards < 1 {
+		errs = append(errs, errors.New("WithRegistryShards needs at least one shard"))
+	}
+
+	if s.limit != nil && s.limit.max < 1 {
+		errs = append(errs, errors.New("WithMaxConnections needs a limit of at least one connection"))
+	}
+	if s.fullPacket != nil && (s.limit == nil || s.limit.queue) {
+		errs = append(errs, errors.New("WithServerFullPacket needs WithMaxConnections to reject connections"))
+	}
+
+	if s.broadcasts.max < 0 {
+		errs = append(errs, errors.New("WithBroadcastCache needs a non-negative number of entries"))
+	}
+
+	if r := s.rates; r != nil {
+		if r.limit.MaxPerIP < 0 || r.limit.PacketsPerSecond < 0 || r.limit.MaxDropped < 0 {
+			errs = append(errs, errors.New("WithRateLimit needs non-negative limits"))
+		}
+		if r.limit.PacketsPerSecond > 0 && r.limit.Burst < 1 {
+			errs = append(errs, errors.New("WithRateLimit needs a burst of at least one packet to limit packets"))
+		}
+	}
+
+	if s.timelineSize < 0 {
+		errs = append(errs, errors.New("WithTimeline needs a non-negative size"))
+	}
+
+	if s.shutdownSpacing < 0 {
+		errs = append(errs, errors.New("WithShutdownOrder needs a non-negative spacing"))
+	}
+
+	if s.udp {
+		if s.udpIdle <= 0 {
+			errs = append(errs, errors.New("WithUDP needs a positive idle timeout"))
+		}
+		if s.inherited != "" || s.tlsConfig != nil || s.webSocket {
+			errs = append(errs, errors.New("WithUDP can't be combined with an inherited listener, TLS or WebSocket"))
+		}
+	}
+
+	if s.unixPath != "" && (s.udp || s.inherited != "") {
+		errs = append(errs, errors.New("WithUnixSocket can't be combined with WithUDP or an inherited listener"))
+	}
+
+	if s.transport != nil && (s.udp || s.unixPath != "" || s.inherited != "") {
+		errs = append(errs, errors.New("WithTransport can't be combined with WithUDP, WithUnixSocket or an inherited listener"))
+	}
+
+	if len(s.addrs) > 0 && (s.inherited != "" || s.unixPath != "") {
+		errs = append(errs, errors.New("WithAddr can't be combined with WithUnixSocket or an inherited listener"))
+	}
+
+	if len(s.injected) > 0 && (len(s.addrs) > 0 || s.inherited != "" || s.unixPath != "" || s.udp || s.transport != nil || s.bindRetries > 0) {
+		errs = append(errs, errors.New("WithListener can't be combined with options that bind or create listeners"))
+	}
+
+	if s.metrics == nil {
+		errs = append(errs, errors.New("WithMetrics needs a Metrics implementation"))
+	}
+
+	return
+}
@@ -0,0 +1,33 @@
+package tcpserve
+
+// Set stores `v` under `key` for the rest of the session, replacing any previous value
+//
+// Values let handlers attach state such as a login, a character ID or an auth token to the connection itself. They
+// are dropped with the session.
+func (s *Session) Set(key string, v interface{}) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	s.values[key] = v
+}
+
+// Get returns the value stored under `key`, if any
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	v, ok := s.values[key]
+
+	return v, ok
+}
+
+// Delete removes the value stored under `key`
+func (s *Session) Delete(key string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	delete(s.values, key)
+}
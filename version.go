@@ -0,0 +1,65 @@
+package tcpserve
+
+import (
+	"errors"
+)
+
+// ErrVersionRejected is reported to `onDisconnected` for sessions turned away by the version gate
+var ErrVersionRejected = errors.New("tcpserve: client version rejected")
+
+// A VersionGate reads the client version from the handshake and turns away clients outside the accepted range
+type VersionGate struct {
+	Extract func(s *Session, packet []byte) (int, bool) // Reads the client version from a packet, if it carries one
+	Min     int                                         // Lowest accepted version
+	Max     int                                         // Highest accepted version; 0 means no upper bound
+	Update  []byte                                      // Packet sent to rejected clients before disconnecting them
+}
+
+// WithVersionGate returns a `ServerOption` which the Server constructor uses to modify its `versionGate` member
+//
+// Every packet is offered to `gate.Extract` until it reports a version. Accepted clients carry on as usual and the
+// version packet still reaches the packet handler; rejected clients are sent `gate.Update` and disconnected.
+func WithVersionGate(gate VersionGate) ServerOption {
+	return func(s *Server) {
+		s.versionGate = &gate
+	}
+}
+
+// ClientVersion returns the version reported by the client, if the server's version gate has seen it yet
+func (s *Session) ClientVersion() (int, bool) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.clientVersion, s.versionKnown
+}
+
+// checkVersion runs `packet` through the version gate, returning false if the session must be disconnected
+func (s *Server) checkVersion(session *Session, packet []byte) bool {
+	if s.versionGate == nil {
+		return true
+	}
+	if _, known := session.ClientVersion(); known {
+		return true
+	}
+
+	version, ok := s.versionGate.Extract(session, packet)
+	if !ok {
+		return true // Not the version packet, keep waiting for it
+	}
+
+	session.stateMu.Lock()
+	session.clientVersion = version
+	session.versionKnown = true
+	session.stateMu.Unlock()
+
+	if version >= s.versionGate.Min && (s.versionGate.Max == 0 || version <= s.versionGate.Max) {
+		return true
+	}
+
+	s.logWarn("Rejecting client with unsupported version", Field{"session", session.id}, Field{"version", version})
+	if s.versionGate.Update != nil {
+		session.Write(s.versionGate.Update) // Best effort, the client is disconnected either way
+	}
+
+	return false
+}
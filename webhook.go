@@ -0,0 +1,104 @@
+package tcpserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// An ApprovalWebhook asks an external service whether each new connection may become a session
+//
+// The webhook receives a JSON `ApprovalRequest` as a POST body and must answer with a 2xx status and a JSON
+// `ApprovalResponse`. Anything else, including a timeout, is a failure handled according to `FailOpen`.
+type ApprovalWebhook struct {
+	URL      string        // Endpoint the connection metadata is POSTed to
+	Timeout  time.Duration // How long to wait for the decision
+	FailOpen bool          // Admit connections when the webhook fails, instead of rejecting them
+	Client   *http.Client  // Client used for the call; `http.DefaultClient` if nil
+}
+
+// An ApprovalRequest is the connection metadata sent to an `ApprovalWebhook`
+type ApprovalRequest struct {
+	RemoteAddr string `json:"remote_addr"`
+	LocalAddr  string `json:"local_addr"`
+}
+
+// An ApprovalResponse is the decision returned by an `ApprovalWebhook`
+type ApprovalResponse struct {
+	Allow bool              `json:"allow"`
+	Tags  map[string]string `json:"tags"` // Attached to the session, see `Session.Tag`
+}
+
+// WithApprovalWebhook returns a `ServerOption` which the Server constructor uses to modify its `approval` member
+//
+// The webhook is called on the connection's own goroutine, so a slow decision never holds up other accepts.
+func WithApprovalWebhook(hook ApprovalWebhook) ServerOption {
+	return func(s *Server) {
+		s.approval = &hook
+	}
+}
+
+// approve asks the approval webhook about `conn`, returning the tags to attach and whether it is admitted
+func (s *Server) approve(conn net.Conn) (map[string]string, bool) {
+	if s.approval == nil {
+		return nil, true
+	}
+
+	res, err := s.approval.call(conn)
+	if err != nil {
+		s.logError("Approval webhook failed", Field{"remote", conn.RemoteAddr()}, Field{"failOpen", s.approval.FailOpen}, Field{"error", err})
+		return nil, s.approval.FailOpen
+	}
+	if !res.Allow {
+		s.logWarn("Approval webhook denied connection", Field{"remote", conn.RemoteAddr()})
+	}
+
+	return res.Tags, res.Allow
+}
+
+// call POSTs the metadata of `conn` to the webhook and decodes its decision
+func (hook *ApprovalWebhook) call(conn net.Conn) (res ApprovalResponse, err error) {
+	body, err := json.Marshal(ApprovalRequest{
+		RemoteAddr: conn.RemoteAddr().String(),
+		LocalAddr:  conn.LocalAddr().String(),
+	})
+	if err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := hook.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("unexpected status %s", resp.Status)
+		return
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+
+	return
+}
@@ -0,0 +1,285 @@
+package tcpserve
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// webSocketMaxMessage is the largest message a `webSocketConn` accepts, as the read loop buffers whole messages
+const webSocketMaxMessage = 1 << 20
+
+// webSocketGUID is appended to the client's key to compute the handshake's accept value (RFC 6455, section 1.3)
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes used by `webSocketConn`
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WithWebSocket returns a `ServerOption` which the Server constructor uses to modify its `webSocketPath` member
+//
+// The server then accepts WebSocket clients instead of raw TCP ones, with everything else unchanged: each binary
+// message's payload is read as it would arrive on a TCP stream, and the default framer sees each whole message, up to
+// 1 MiB and however fragmented, as one packet. Each write is sent as one binary message. An empty `path` accepts upgrades on any path. Combined with `WithTLS`
+// the server speaks wss.
+func WithWebSocket(path string) ServerOption {
+	return func(s *Server) {
+		s.webSocket = true
+		s.webSocketPath = path
+	}
+}
+
+// NewWebSocketListener wraps `ln` so that its connections speak WebSocket, see `WithWebSocket`
+//
+// The upgrade handshake runs on a connection's first read or write, so a slow client never blocks `Accept`.
+func NewWebSocketListener(ln net.Listener, path string) net.Listener {
+	return &webSocketListener{Listener: ln, path: path}
+}
+
+// webSocketListener accepts connections that are upgraded to WebSocket
+type webSocketListener struct {
+	net.Listener
+	path string // Required request path, if any
+}
+
+func (l *webSocketListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &webSocketConn{Conn: conn, path: l.path, br: bufio.NewReader(conn)}, nil
+}
+
+// webSocketConn carries a byte stream over the binary messages of a server-side WebSocket connection
+type webSocketConn struct {
+	net.Conn
+	path string
+	br   *bufio.Reader // Buffers the handshake, which may be followed by frames in the same read
+
+	handshakeOnce sync.Once
+	handshakeErr  error
+
+	remaining int64   // Payload bytes of the current frame still to be read
+	fin       bool    // Whether the current frame is the last of its message
+	received  int64   // Payload bytes of the current message read so far
+	mask      [4]byte // Masking key of the current frame
+	maskPos   int     // Position in `mask` of the next payload byte
+
+	writeMu sync.Mutex // Keeps frames from concurrent writers, pongs included, from interleaving
+}
+
+// NetConn returns the connection the WebSocket runs on
+func (c *webSocketConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// handshake answers the client's upgrade request, once
+func (c *webSocketConn) handshake() error {
+	c.handshakeOnce.Do(func() {
+		c.handshakeErr = c.upgrade()
+	})
+
+	return c.handshakeErr
+}
+
+// upgrade reads the client's HTTP upgrade request and accepts or refuses it
+func (c *webSocketConn) upgrade() error {
+	req, err := http.ReadRequest(c.br)
+	if err != nil {
+		return fmt.Errorf("tcpserve: reading websocket upgrade: %w", err)
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case req.Method != http.MethodGet,
+		!headerHas(req.Header, "Connection", "upgrade"),
+		!headerHas(req.Header, "Upgrade", "websocket"),
+		req.Header.Get("Sec-WebSocket-Version") != "13",
+		key == "":
+		c.Conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nConnection: close\r\n\r\n"))
+		return errors.New("tcpserve: invalid websocket upgrade request")
+	case c.path != "" && req.URL.Path != c.path:
+		c.Conn.Write([]byte("HTTP/1.1 404 Not Found\r\nConnection: close\r\n\r\n"))
+		return fmt.Errorf("tcpserve: websocket upgrade for unknown path %q", req.URL.Path)
+	}
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	_, err = c.Conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"))
+
+	return err
+}
+
+// headerHas reports whether the comma-separated header `name` contains `token`, ignoring case
+func headerHas(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Read reads the payload of the client's binary messages, never more than one frame's worth per call
+//
+// `midMessage` tells the read loop whether the message goes on.
+func (c *webSocketConn) Read(p []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+		if c.remaining == 0 && c.fin && c.received > 0 {
+			return 0, nil // An empty final fragment ends the message read so far
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	c.unmask(p[:n])
+	c.remaining -= int64(n)
+
+	return n, err
+}
+
+// nextFrame reads frame headers until a data frame starts, answering control frames on the way
+func (c *webSocketConn) nextFrame() error {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return err
+	}
+
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return errors.New("tcpserve: unmasked websocket frame from client")
+	}
+
+	length := int64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) &^ (1 << 63)) // The most significant bit must be 0
+	}
+
+	if _, err := io.ReadFull(c.br, c.mask[:]); err != nil {
+		return err
+	}
+	c.maskPos = 0
+
+	switch opcode {
+	case wsBinary, wsContinuation:
+		if opcode == wsBinary {
+			c.received = 0 // A new message starts
+		}
+		c.received += length
+		if c.received > webSocketMaxMessage {
+			c.writeFrame(wsClose, []byte{0x03, 0xF1}) // 1009: message too big
+			return fmt.Errorf("tcpserve: websocket message over %d bytes", webSocketMaxMessage)
+		}
+		c.remaining = length // Data frame, its payload is read by `Read`
+		c.fin = head[0]&0x80 != 0
+		return nil
+	case wsText:
+		c.writeFrame(wsClose, []byte{0x03, 0xEB}) // 1003: unsupported data
+		return errors.New("tcpserve: websocket text frames are not supported")
+	}
+
+	if length > 125 {
+		return errors.New("tcpserve: oversized websocket control frame")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	c.unmask(payload)
+
+	switch opcode {
+	case wsPing:
+		_, err := c.writeFrame(wsPong, payload)
+		return err
+	case wsClose:
+		c.writeFrame(wsClose, payload) // Echo the status code to complete the closing handshake
+		return io.EOF
+	default:
+		return nil // Unsolicited pongs and unknown control frames are ignored
+	}
+}
+
+// midMessage reports whether the current message has payload left to read
+func (c *webSocketConn) midMessage() bool {
+	return c.remaining > 0 || !c.fin
+}
+
+// unmask unmasks `payload`, the next bytes of the current frame, in place
+func (c *webSocketConn) unmask(payload []byte) {
+	for i := range payload {
+		payload[i] ^= c.mask[c.maskPos&3]
+		c.maskPos += 1
+	}
+}
+
+// Write sends `p` as one binary message
+func (c *webSocketConn) Write(p []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+
+	if _, err := c.writeFrame(wsBinary, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// writeFrame sends a single unmasked, final frame
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) (int, error) {
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.Conn.Write(frame)
+}
@@ -0,0 +1,245 @@
+package tcpserve
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// recordConn is a `net.Conn` which keeps everything written to it
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	return c.written.Write(p)
+}
+
+// clientFrame builds a frame as a client sends it, masked unless `unmasked` is set
+func clientFrame(fin bool, opcode byte, payload []byte, unmasked bool) []byte {
+	frame := []byte{opcode}
+	if fin {
+		frame[0] |= 0x80
+	}
+
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	if unmasked {
+		return append(frame, payload...)
+	}
+
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i&3])
+	}
+
+	return frame
+}
+
+func TestWebSocketConnRead(t *testing.T) {
+	long := bytes.Repeat([]byte("0123456789"), 20)   // Needs a 2 byte extended length
+	huge := bytes.Repeat([]byte("0123456789"), 7000) // Needs an 8 byte extended length
+	half := make([]byte, webSocketMaxMessage/2+1)    // Two of them exceed the message limit
+
+	tests := []struct {
+		name    string
+		frames  [][]byte
+		data    []byte // Payload bytes read before the stream ended
+		written []byte // Control frames the connection answered with
+		errAny  bool
+	}{
+		{
+			name:   "masked binary frame",
+			frames: [][]byte{clientFrame(true, wsBinary, []byte("hello"), false)},
+			data:   []byte("hello"),
+		},
+		{
+			name:   "unmasked binary frame",
+			frames: [][]byte{clientFrame(true, wsBinary, []byte("hello"), true)},
+			errAny: true,
+		},
+		{
+			name:   "2 byte extended length",
+			frames: [][]byte{clientFrame(true, wsBinary, long, false)},
+			data:   long,
+		},
+		{
+			name:   "8 byte extended length",
+			frames: [][]byte{clientFrame(true, wsBinary, huge, false)},
+			data:   huge,
+		},
+		{
+			name: "fragmented message",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, []byte("hel"), false),
+				clientFrame(false, wsContinuation, []byte("lo "), false),
+				clientFrame(true, wsContinuation, []byte("world"), false),
+			},
+			data: []byte("hello world"),
+		},
+		{
+			name: "ping between fragments",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, []byte("hel"), false),
+				clientFrame(true, wsPing, []byte("hi"), false),
+				clientFrame(true, wsContinuation, []byte("lo"), false),
+			},
+			data:    []byte("hello"),
+			written: []byte{0x80 | wsPong, 2, 'h', 'i'},
+		},
+		{
+			name: "pong between fragments",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, []byte("hel"), false),
+				clientFrame(true, wsPong, nil, false),
+				clientFrame(true, wsContinuation, []byte("lo"), false),
+			},
+			data: []byte("hello"),
+		},
+		{
+			name: "close between fragments",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, []byte("hel"), false),
+				clientFrame(true, wsClose, []byte{0x03, 0xE8}, false),
+				clientFrame(true, wsContinuation, []byte("lo"), false),
+			},
+			data:    []byte("hel"),
+			written: []byte{0x80 | wsClose, 2, 0x03, 0xE8},
+		},
+		{
+			name:    "text frame",
+			frames:  [][]byte{clientFrame(true, wsText, []byte("hello"), false)},
+			written: []byte{0x80 | wsClose, 2, 0x03, 0xEB},
+			errAny:  true,
+		},
+		{
+			name: "oversized message",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, half, false),
+				clientFrame(true, wsContinuation, half, false),
+			},
+			data:    half,
+			written: []byte{0x80 | wsClose, 2, 0x03, 0xF1},
+			errAny:  true,
+		},
+		{
+			name:   "oversized control frame",
+			frames: [][]byte{clientFrame(true, wsPing, long, false)},
+			errAny: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := &recordConn{}
+			conn := &webSocketConn{Conn: raw, br: bufio.NewReader(bytes.NewReader(bytes.Join(test.frames, nil)))}
+			conn.handshakeOnce.Do(func() {}) // Start right after the upgrade
+
+			data, err := io.ReadAll(conn)
+			if (err != nil) != test.errAny {
+				t.Fatalf("Read error = %v, want error %v", err, test.errAny)
+			}
+			if !bytes.Equal(data, test.data) {
+				t.Errorf("Read %d bytes %q, want %d bytes", len(data), truncate(data), len(test.data))
+			}
+			if !bytes.Equal(raw.written.Bytes(), test.written) {
+				t.Errorf("wrote %v, want %v", raw.written.Bytes(), test.written)
+			}
+		})
+	}
+}
+
+func TestWebSocketConnUpgrade(t *testing.T) {
+	request := "GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	stream := append([]byte(request), clientFrame(true, wsBinary, []byte("hello"), false)...) // Frame in the same read
+
+	raw := &recordConn{}
+	conn := &webSocketConn{Conn: raw, path: "/ws", br: bufio.NewReader(bytes.NewReader(stream))}
+
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Read error = %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("Read %q, want %q", data, "hello")
+	}
+
+	// Accept value of the key from RFC 6455, section 1.3
+	if !bytes.Contains(raw.written.Bytes(), []byte("Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n")) {
+		t.Errorf("handshake response %q lacks the accept value", raw.written.Bytes())
+	}
+}
+
+// truncate shortens `data` for error messages
+func truncate(data []byte) []byte {
+	if len(data) > 32 {
+		return data[:32]
+	}
+
+	return data
+}
+
+func TestWebSocketMessageIsOnePacket(t *testing.T) {
+	message := bytes.Repeat([]byte("0123456789"), 500) // Larger than a single read
+
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{name: "single frame", frames: [][]byte{clientFrame(true, wsBinary, message, false)}},
+		{
+			name: "fragments around a ping",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, message[:3000], false),
+				clientFrame(true, wsPing, nil, false),
+				clientFrame(true, wsContinuation, message[3000:], false),
+			},
+		},
+		{
+			name: "empty final fragment",
+			frames: [][]byte{
+				clientFrame(false, wsBinary, message, false),
+				clientFrame(true, wsContinuation, nil, false),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frames := append(bytes.Join(test.frames, nil), clientFrame(true, wsBinary, []byte("next"), false)...)
+			conn := &webSocketConn{Conn: &recordConn{}, br: bufio.NewReader(bytes.NewReader(frames))}
+			conn.handshakeOnce.Do(func() {}) // Start right after the upgrade
+
+			var packets [][]byte
+			readFrames(NewSession(WithConn(conn)), defaultBuffers, time.Now, func(_, packet []byte) error {
+				packets = append(packets, append([]byte(nil), packet...))
+				return nil
+			})
+
+			if len(packets) != 2 {
+				t.Fatalf("read %d packets, want 2", len(packets))
+			}
+			if !bytes.Equal(packets[0], message[legacyHeaderSize:]) {
+				t.Errorf("first packet of %d bytes, want %d", len(packets[0]), len(message)-legacyHeaderSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package tcpserve
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Directions and stages of the frames dumped by `WithWireTrace`
+const (
+	traceIn    = "in"    // Read from the client
+	traceOut   = "out"   // Written to the client
+	traceWire  = "wire"  // As seen on the connection, framed and encrypted
+	tracePlain = "plain" // As seen by handlers, decrypted and without framing
+)
+
+// WithWireTrace returns a `ServerOption` which the Server constructor uses to modify its `wireTrace` member
+//
+// Every frame read or written by a session is dumped to `w` in hex and ASCII, once as it crosses the wire and once
+// as the handlers see it, each tagged with its direction and session ID. Dumps of concurrent sessions never
+// interleave. Tracing is meant for debugging protocols and codecs; it slows every packet down.
+func WithWireTrace(w io.Writer) ServerOption {
+	return func(s *Server) {
+		s.wireTrace = &wireTracer{w: w}
+	}
+}
+
+// wireTracer serializes the dumps of `WithWireTrace` onto its writer
+type wireTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// dump writes `data` with a header naming the session, direction and stage
+func (t *wireTracer) dump(id int, direction, stage string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintf(t.w, "session %d %s %s, %d bytes\n%s", id, direction, stage, len(data), hex.Dump(data))
+}
+
+// trace dumps `data` if the session's server traces the wire
+func (s *Session) trace(direction, stage string, data []byte) {
+	if s.tracer != nil {
+		s.tracer.dump(s.id, direction, stage, data)
+	}
+}
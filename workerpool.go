@@ -0,0 +1,126 @@
+package tcpserve
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// sessionQueueSize is the number of packets a session may have waiting for a worker before its reads are held off
+const sessionQueueSize = 64
+
+// WithWorkerPool returns a `ServerOption` which the Server constructor uses to modify its `workers` member
+//
+// Decoded packets are handled by a pool of `n` worker goroutines instead of each connection's read goroutine, so a
+// slow handler no longer stalls its client's reads. Packets of a session are still handled one at a time and in
+// order. When a session has too many packets waiting, its reads are held off until the workers catch up.
+func WithWorkerPool(n int) ServerOption {
+	return func(s *Server) {
+		s.workers = n
+	}
+}
+
+// WithCodecBarrier returns a `ServerOption` which the Server constructor uses to modify its `codecBarrier` member
+//
+// With a worker pool, the read goroutine keeps splitting and decrypting a session's packets while a worker handles
+// earlier ones, so a handler calling `Session.SwapCodecs` or `Session.UpgradeTLS` would see the following packets
+// already decoded the old way. Packets `isBarrier` returns true for, typically the negotiation or STARTTLS packet,
+// make the read goroutine wait until their handler returned before decoding anything after them. Without a worker
+// pool, packets are always handled before the next one is decoded and the option has no effect.
+func WithCodecBarrier(isBarrier func(session *Session, packet []byte) bool) ServerOption {
+	return func(s *Server) {
+		s.codecBarrier = isBarrier
+	}
+}
+
+// A job is a decoded packet waiting for a worker
+type job struct {
+	ctx    context.Context
+	raw    []byte // Wire bytes, for `onRawPacket`
+	packet []byte // Decoded packet
+	buf    []byte // Pooled buffer holding the packet, released once it has been delivered
+}
+
+// workerPool runs the packet handlers of every session on a fixed set of goroutines
+type workerPool struct {
+	ready   chan *Session // Sessions with queued packets, waiting for a worker
+	wg      sync.WaitGroup
+	mu      sync.RWMutex // Held for reading while sending on `ready`, so `stop` can't close it under a sender
+	stopped bool
+}
+
+// mailbox is the queue of a session's packets waiting for the worker pool
+type mailbox struct {
+	jobs      chan job
+	scheduled int32          // Whether the session is in the pool's ready queue or being drained, accessed atomically
+	pending   sync.WaitGroup // Queued and running jobs
+}
+
+// startWorkerPool starts `n` workers delivering queued packets with `deliver`
+func startWorkerPool(n int, deliver func(*Session, job)) *workerPool {
+	p := &workerPool{ready: make(chan *Session, n)}
+
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer p.wg.Done()
+
+			for session := range p.ready {
+				p.drain(session, deliver)
+			}
+		}()
+	}
+
+	return p
+}
+
+// enqueue queues a packet for `session`, blocking while the session's queue is full
+func (p *workerPool) enqueue(session *Session, j job) {
+	box := session.mailbox
+	box.pending.Add(1)
+	box.jobs <- j
+
+	p.schedule(session)
+}
+
+// schedule hands `session` to a worker unless one already has it
+func (p *workerPool) schedule(session *Session) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return // A late reschedule from `drain`, every session is gone
+	}
+	if atomic.CompareAndSwapInt32(&session.mailbox.scheduled, 0, 1) {
+		p.ready <- session
+	}
+}
+
+// drain delivers the session's queued packets, in order, until its queue is empty
+func (p *workerPool) drain(session *Session, deliver func(*Session, job)) {
+	box := session.mailbox
+
+	for {
+		select {
+		case j := <-box.jobs:
+			deliver(session, j)
+			box.pending.Done()
+		default:
+			atomic.StoreInt32(&box.scheduled, 0)
+			if len(box.jobs) > 0 {
+				go p.schedule(session) // A packet raced in after we looked; never block a worker on the ready queue
+			}
+			return
+		}
+	}
+}
+
+// stop waits for the workers to finish once every session is gone
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	close(p.ready)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
@@ -0,0 +1,109 @@
+package tcpserve
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolKeepsSessionOrder(t *testing.T) {
+	const sessions, packets = 8, 500
+
+	var mu sync.Mutex
+	got := make(map[int][]int)
+	pool := startWorkerPool(4, func(session *Session, j job) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[session.id] = append(got[session.id], int(j.packet[0])<<8|int(j.packet[1]))
+	})
+
+	var wg sync.WaitGroup
+	boxes := make([]*Session, sessions)
+	for i := range boxes {
+		session := NewSession(WithId(i))
+		session.mailbox = &mailbox{jobs: make(chan job, sessionQueueSize)}
+		boxes[i] = session
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < packets; n++ {
+				pool.enqueue(session, job{packet: []byte{byte(n >> 8), byte(n)}})
+			}
+		}()
+	}
+	wg.Wait()
+	for _, session := range boxes {
+		session.mailbox.pending.Wait()
+	}
+	pool.stop()
+
+	for id := 0; id < sessions; id++ {
+		if len(got[id]) != packets {
+			t.Fatalf("session %d got %d packets, want %d", id, len(got[id]), packets)
+		}
+		for n, packet := range got[id] {
+			if packet != n {
+				t.Fatalf("session %d got packet %d at position %d", id, packet, n)
+			}
+		}
+	}
+}
+
+func TestWorkerPoolScheduleAfterStop(t *testing.T) {
+	pool := startWorkerPool(1, func(*Session, job) {})
+	pool.stop()
+
+	session := NewSession()
+	session.mailbox = &mailbox{jobs: make(chan job, 1)}
+	pool.schedule(session) // A late reschedule from `drain` must not send on the closed queue
+}
+
+func TestCodecBarrierSwapsBeforeNextPacket(t *testing.T) {
+	xor := func(b []byte) []byte {
+		for i := range b {
+			b[i] ^= 0x55
+		}
+		return b
+	}
+
+	got := make(chan string, 2)
+	s, wg := startTestServer(t,
+		WithWorkerPool(2),
+		WithSessionOptions(WithFramer(NewLengthPrefixFramer())),
+		WithCodecBarrier(func(_ *Session, packet []byte) bool { return string(packet) == "swap" }),
+		WithOnPacket(func(session *Session, packet []byte) {
+			if string(packet) == "swap" {
+				session.SwapCodecs(CodecSet{Decrypt: xor}, nil)
+			}
+			got <- string(packet)
+		}))
+	defer func() {
+		s.Stop()
+		wg.Wait()
+	}()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	framer := NewLengthPrefixFramer()
+	stream := append(framer.Frame([]byte("swap")), framer.Frame(xor([]byte("secret")))...)
+	if _, err := conn.Write(stream); err != nil { // Both frames in one write, so they are read together
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"swap", "secret"} {
+		select {
+		case packet := <-got:
+			if packet != want {
+				t.Fatalf("handler got %q, want %q", packet, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("handler never got %q", want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package tcpserve
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WriteStats summarizes the server's write path since it was created
+type WriteStats struct {
+	Writes uint64        // Writes issued to connections, each one system call or more
+	Bytes  uint64        // Bytes handed to connections
+	Window time.Duration // Time the counters cover
+}
+
+// WritesPerSecond returns the average number of writes issued per second
+func (w WriteStats) WritesPerSecond() float64 {
+	if w.Window <= 0 {
+		return 0
+	}
+
+	return float64(w.Writes) / w.Window.Seconds()
+}
+
+// BytesPerWrite returns the average number of bytes handed over per write
+func (w WriteStats) BytesPerWrite() float64 {
+	if w.Writes == 0 {
+		return 0
+	}
+
+	return float64(w.Bytes) / float64(w.Writes)
+}
+
+// writeCounters is shared by every session of a server to count their writes
+type writeCounters struct {
+	writes  uint64 // First for 64-bit atomic alignment
+	bytes   uint64
+	since   time.Time
+	metrics Metrics // Also told about every write, if set
+}
+
+// add records a write of `n` bytes that ended with `err`
+func (c *writeCounters) add(n int, err error) {
+	if c == nil {
+		return // Session is not owned by a server
+	}
+
+	atomic.AddUint64(&c.writes, 1)
+	atomic.AddUint64(&c.bytes, uint64(n))
+
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.PacketSent(n)
+	if err != nil {
+		c.metrics.Error(ErrorWrite)
+	}
+}
+
+// WriteStats returns the write counters of every session the server has handled
+func (s *Server) WriteStats() WriteStats {
+	return WriteStats{
+		Writes: atomic.LoadUint64(&s.writes.writes),
+		Bytes:  atomic.LoadUint64(&s.writes.bytes),
+		Window: time.Since(s.writes.since),
+	}
+}